@@ -5,45 +5,421 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
+	"github.com/kolkov/gportage/internal/config"
+	"github.com/kolkov/gportage/internal/pkg"
+	"github.com/kolkov/gportage/internal/profile"
 	"github.com/kolkov/gportage/internal/repo"
+	"github.com/kolkov/gportage/internal/sets"
 	"github.com/kolkov/gportage/internal/solver"
 	"github.com/kolkov/gportage/internal/state"
+	"github.com/kolkov/gportage/internal/useflags"
+	"github.com/kolkov/gportage/internal/vdb"
+	"github.com/kolkov/gportage/internal/visibility"
 	"github.com/spf13/cobra"
 )
 
 var (
-	useMockRepo bool
+	useMockRepo      bool
+	generateMetadata bool
+	allowNetwork     bool
+	cacheDir         string
+	regenPretend     bool
+	autounmask       bool
+	autounmaskWrite  bool
+	usePkgOnly       bool
 )
 
 var (
-	repoPath    = "/var/db/repos/gentoo"
-	snapshotDir = "/.snapshots"
-	fsType      = "btrfs"
+	configRoot     = "/etc/portage"
+	vdbPath        = vdb.DefaultPath
+	worldDir       = sets.DefaultWorldDir
+	repoPath       string
+	snapshotDir    string
+	fsType         string
+	profilePath    string
+	arch           string
+	acceptKeywords []string
 )
 
+// loadConfig загружает профиль и make.conf из configRoot.
+// Флаги командной строки имеют приоритет над значениями из файлов конфигурации.
+func loadConfig() (*config.Config, *profile.Profile) {
+	// Путь к репозиторию нужен до загрузки профиля для записей "gentoo:path" в файлах parent
+	path := repoPath
+	if main := loadReposConf().Main(); path == "" && main != nil {
+		path = main.Location
+	}
+	if path == "" {
+		base, err := config.Load(configRoot, nil, nil)
+		if err != nil {
+			log.Fatalf("Failed to read make.conf: %v", err)
+		}
+		path = base.RepoPath
+	}
+
+	prof := loadProfile(path)
+
+	var cfg *config.Config
+	var err error
+	if prof != nil {
+		cfg, err = config.Load(configRoot, prof.Vars, prof.Incremental)
+	} else {
+		cfg, err = config.Load(configRoot, nil, nil)
+	}
+	if err != nil {
+		log.Fatalf("Failed to read make.conf: %v", err)
+	}
+
+	cfg.RepoPath = path
+	if snapshotDir != "" {
+		cfg.SnapshotDir = snapshotDir
+	}
+	if fsType != "" {
+		cfg.FSType = fsType
+	}
+	if arch != "" {
+		cfg.Arch = arch
+	}
+	if len(acceptKeywords) > 0 {
+		cfg.AddIncremental("ACCEPT_KEYWORDS", acceptKeywords)
+	}
+	return cfg, prof
+}
+
+// loadProfile загружает каскадный профиль (по умолчанию <config-root>/make.profile).
+// Возвращает nil, если профиль не выбран.
+func loadProfile(repoPath string) *profile.Profile {
+	path := profilePath
+	if path == "" {
+		path = filepath.Join(configRoot, "make.profile")
+		if _, err := os.Stat(path); err != nil {
+			log.Printf("No profile selected at %s", path)
+			return nil
+		}
+	}
+
+	// Репозитории нужны для записей вида "gentoo:default/linux" в файлах parent
+	rc := loadReposConf()
+	var repos []*profile.Repository
+	addRepo := func(name, location string, confMasters []string) {
+		layout, err := repo.LoadLayout(location)
+		if err != nil {
+			log.Printf("Warning: repository %s: %v", name, err)
+			return
+		}
+		repos = append(repos, &profile.Repository{
+			Name:     name,
+			Location: location,
+			Masters:  repoMasters(name, confMasters, layout, rc.Main()),
+			Portage2: layout.HasProfileFormat("portage-2"),
+		})
+	}
+	if layout, err := repo.LoadLayout(repoPath); err == nil {
+		var confMasters []string
+		if r := rc.Get(layout.Name); r != nil {
+			confMasters = r.Masters
+		}
+		addRepo(layout.Name, repoPath, confMasters)
+	}
+	for _, r := range rc.Repos {
+		addRepo(r.Name, r.Location, r.Masters)
+	}
+	// Без repos.conf каталог репозитория доступен и под именем gentoo
+	if rc.Get("gentoo") == nil {
+		addRepo("gentoo", repoPath, nil)
+	}
+
+	prof, err := profile.Load(path, repos)
+	if err != nil {
+		log.Fatalf("Profile error: %v", err)
+	}
+	log.Printf("Using profile: %s (%d nodes)", path, len(prof.Nodes))
+	return prof
+}
+
+// newVisibilityFilter создает фильтр видимости из эффективной конфигурации
+// и файлов в configRoot
+func newVisibilityFilter(cfg *config.Config, prof *profile.Profile) *visibility.Filter {
+	filter := visibility.NewFilter()
+
+	if cfg.Arch != "" {
+		keywords := visibility.NewKeywordRule(cfg.Arch, cfg.AcceptKeywords)
+		if err := keywords.LoadPackageKeywords(filepath.Join(configRoot, "package.accept_keywords")); err != nil {
+			log.Fatalf("Failed to read package.accept_keywords: %v", err)
+		}
+		filter.AddRule(keywords)
+	}
+
+	licenses := visibility.NewLicenseRule(cfg.Incremental["ACCEPT_LICENSE"])
+	if !useMockRepo {
+		if err := licenses.LoadGroups(filepath.Join(cfg.RepoPath, "profiles", "license_groups")); err != nil {
+			log.Fatalf("Failed to read license_groups: %v", err)
+		}
+	}
+	if err := licenses.LoadPackageLicenses(filepath.Join(configRoot, "package.license")); err != nil {
+		log.Fatalf("Failed to read package.license: %v", err)
+	}
+	filter.AddRule(licenses)
+
+	// Маски применяются в порядке Portage, чтобы "-atom" отменял маски предыдущих уровней:
+	// profiles/package.mask репозитория, узлы профиля, затем package.mask пользователя
+	masks := visibility.NewMaskRule()
+	if !useMockRepo {
+		path := filepath.Join(cfg.RepoPath, "profiles", "package.mask")
+		if err := masks.LoadMasks(path); err != nil {
+			log.Fatalf("Failed to read %s: %v", path, err)
+		}
+	}
+	if prof != nil {
+		masks.AddMasks(prof.PackageMask)
+	}
+	if err := masks.LoadMasks(filepath.Join(configRoot, "package.mask")); err != nil {
+		log.Fatalf("Failed to read package.mask: %v", err)
+	}
+	if err := masks.LoadUnmasks(filepath.Join(configRoot, "package.unmask")); err != nil {
+		log.Fatalf("Failed to read package.unmask: %v", err)
+	}
+	filter.AddRule(masks)
+
+	return filter
+}
+
+// newUseCalculator создает вычисление USE-флагов из профиля, make.conf,
+// package.use и переменной окружения USE
+func newUseCalculator(cfg *config.Config, prof *profile.Profile) *useflags.Calculator {
+	calc := useflags.NewCalculator(prof, cfg)
+	if err := calc.LoadPackageUse(filepath.Join(configRoot, "package.use")); err != nil {
+		log.Fatalf("Failed to read package.use: %v", err)
+	}
+	calc.EnvUse = strings.Fields(os.Getenv("USE"))
+	return calc
+}
+
+// formatUse выводит состояние флагов IUSE в стиле emerge: USE="a -b"
+func formatUse(p *pkg.Package) string {
+	if len(p.UseFlags) == 0 {
+		return ""
+	}
+	flags := make([]string, 0, len(p.UseFlags))
+	for flag := range p.UseFlags {
+		flags = append(flags, flag)
+	}
+	sort.Strings(flags)
+	for i, flag := range flags {
+		if !p.UseFlags[flag] {
+			flags[i] = "-" + flag
+		}
+	}
+	return fmt.Sprintf(" USE=%q", strings.Join(flags, " "))
+}
+
+// loadReposConf читает <config-root>/repos.conf
+func loadReposConf() *repo.ReposConf {
+	rc, err := repo.LoadReposConf(filepath.Join(configRoot, "repos.conf"))
+	if err != nil {
+		log.Fatalf("Failed to read repos.conf: %v", err)
+	}
+	return rc
+}
+
+// newRepository открывает репозитории пакетов: при --repo - только указанный каталог,
+// иначе все репозитории из repos.conf, а без них - PORTDIR.
+// Если задан gen, недостающие метаданные генерируются через bash.
+func newRepository(cfg *config.Config, gen *repo.MetadataGenerator) (*repo.CompositeRepository, error) {
+	composite := repo.NewCompositeRepository()
+
+	rc := loadReposConf()
+	if repoPath != "" || len(rc.Repos) == 0 {
+		pr, err := openRepository(cfg.RepoPath, rc)
+		if err != nil {
+			return nil, err
+		}
+		pr.Generator = gen
+		composite.Add(pr.Name(), 0, pr)
+		return composite, nil
+	}
+
+	opened := make(map[string]*repo.PortageRepository)
+	for _, r := range rc.Repos {
+		pr, err := repo.NewPortageRepository(r.Location)
+		if err != nil {
+			log.Printf("Warning: repository %s: %v", r.Name, err)
+			continue
+		}
+		pr.Generator = gen
+		if pr.Name() != r.Name {
+			log.Printf("Warning: repository %s is named %s in %s", r.Name, pr.Name(), filepath.Join(pr.Path, "profiles", "repo_name"))
+		}
+		log.Printf("Using repository %s (priority %d): %s", r.Name, r.Priority, pr.Path)
+		opened[r.Name] = pr
+		composite.Add(r.Name, r.Priority, pr)
+	}
+	if len(opened) == 0 {
+		return nil, fmt.Errorf("no repositories available from repos.conf")
+	}
+
+	for _, r := range rc.Repos {
+		pr := opened[r.Name]
+		if pr == nil {
+			continue
+		}
+		for _, m := range repoMasters(r.Name, r.Masters, pr.Layout, rc.Main()) {
+			if mp := opened[m]; mp != nil {
+				pr.Masters = append(pr.Masters, mp)
+			} else {
+				log.Printf("Warning: repository %s: unknown master %s", r.Name, m)
+			}
+		}
+	}
+	return composite, nil
+}
+
+// openRepository открывает один репозиторий и подключает его мастеров из repos.conf:
+// они используются только для поиска eclass и профилей
+func openRepository(path string, rc *repo.ReposConf) (*repo.PortageRepository, error) {
+	pr, err := repo.NewPortageRepository(path)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Using repository: %s", pr.Path)
+
+	var confMasters []string
+	if r := rc.Get(pr.Name()); r != nil {
+		confMasters = r.Masters
+	}
+	for _, m := range repoMasters(pr.Name(), confMasters, pr.Layout, rc.Main()) {
+		mc := rc.Get(m)
+		if mc == nil {
+			log.Printf("Warning: repository %s: master %s is not configured in repos.conf", pr.Name(), m)
+			continue
+		}
+		mp, err := repo.NewPortageRepository(mc.Location)
+		if err != nil {
+			log.Printf("Warning: repository %s: master %s: %v", pr.Name(), m, err)
+			continue
+		}
+		pr.Masters = append(pr.Masters, mp)
+	}
+	return pr, nil
+}
+
+// repoMasters возвращает мастеров репозитория: из repos.conf, затем из layout.conf;
+// без них оверлеи наследуют eclass и профили основного репозитория
+func repoMasters(name string, confMasters []string, layout *repo.Layout, main *repo.RepoConfig) []string {
+	if confMasters != nil {
+		return confMasters
+	}
+	if layout.Masters != nil {
+		return layout.Masters
+	}
+	if main != nil && name != main.Name {
+		return []string{main.Name}
+	}
+	return nil
+}
+
+// formatPackage возвращает category/package-version с репозиторием версии
+func formatPackage(p *pkg.Package) string {
+	if p.Repository == "" {
+		return p.Name + "-" + p.Version
+	}
+	return p.Name + "-" + p.Version + "::" + p.Repository
+}
+
+// loadSets создает реестр наборов пакетов: стандартные наборы,
+// файлы <config-root>/sets и секции <config-root>/sets.conf
+func loadSets(cfg *config.Config, prof *profile.Profile) *sets.Sets {
+	var system []string
+	if prof != nil {
+		system = prof.System()
+	}
+	db := vdb.New(vdbPath)
+	registry := sets.NewStandard(worldDir, system, db)
+
+	if err := registry.LoadDir(filepath.Join(configRoot, "sets")); err != nil {
+		log.Fatalf("Failed to read package sets: %v", err)
+	}
+	src := sets.Sources{DB: db}
+	if r, err := newRepository(cfg, nil); err == nil {
+		src.Repo = r
+	}
+	if err := registry.LoadConfig(filepath.Join(configRoot, "sets.conf"), src); err != nil {
+		log.Fatalf("Failed to read sets.conf: %v", err)
+	}
+	return registry
+}
+
+// expandSets раскрывает наборы пакетов (@world, @system, ...) в аргументах команды
+func expandSets(args []string, cfg *config.Config, prof *profile.Profile) []string {
+	atoms, err := loadSets(cfg, prof).Expand(args)
+	if err != nil {
+		log.Fatalf("Failed to expand package sets: %v", err)
+	}
+	return atoms
+}
+
+// applyChanges печатает изменения конфигурации, предложенные autounmask,
+// и при --autounmask-write дописывает их в файлы configRoot.
+// Возвращает false, если без этих изменений продолжать нельзя.
+func applyChanges(changes []visibility.Change) bool {
+	if len(changes) == 0 {
+		return true
+	}
+
+	fmt.Println("The following configuration changes are necessary to proceed:")
+	file := ""
+	for _, c := range changes {
+		if c.File != file {
+			file = c.File
+			fmt.Printf("\n%s:\n", filepath.Join(configRoot, file))
+		}
+		if c.Comment != "" {
+			fmt.Printf("# %s\n", c.Comment)
+		}
+		fmt.Println(c.Entry)
+	}
+	fmt.Println()
+
+	if !autounmaskWrite {
+		fmt.Println("Use --autounmask-write to write these changes to the config files.")
+		return false
+	}
+	for _, c := range changes {
+		if err := config.AppendEntry(filepath.Join(configRoot, c.File), c.Comment, c.Entry); err != nil {
+			log.Fatalf("Failed to write %s: %v", c.File, err)
+		}
+	}
+	fmt.Printf("Changes written to %s\n", configRoot)
+	return true
+}
+
 var rootCmd = &cobra.Command{
 	Use:   "gportage",
 	Short: "Next-generation package manager for Gentoo",
 }
 
 var resolveCmd = &cobra.Command{
-	Use:   "resolve [package...]",
+	Use:   "resolve [package|@set...]",
 	Short: "Resolve package dependencies",
 	Args:  cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		cfg, prof := loadConfig()
+
 		var r repo.Repository
 		var err error
 
 		if !useMockRepo {
-			// Преобразуем путь в абсолютный только для реального репозитория
-			absRepoPath, absErr := filepath.Abs(repoPath)
-			if absErr != nil {
-				log.Fatalf("Invalid repository path: %v", absErr)
+			var gen *repo.MetadataGenerator
+			if generateMetadata {
+				gen, err = repo.NewMetadataGenerator(allowNetwork)
+				if err != nil {
+					log.Fatalf("Metadata generator error: %v", err)
+				}
 			}
-			log.Printf("Using repository: %s", absRepoPath)
-
-			r, err = repo.NewPortageRepository(absRepoPath)
+			r, err = newRepository(cfg, gen)
 			if err != nil {
 				log.Fatalf("Repository error: %v", err)
 			}
@@ -53,10 +429,21 @@ var resolveCmd = &cobra.Command{
 		}
 
 		resolver := solver.NewResolver(r)
-		solution, err := resolver.Resolve(args)
+		if !useMockRepo {
+			resolver.SetInstalled(vdb.New(vdbPath))
+		}
+		resolver.SetVisibility(newVisibilityFilter(cfg, prof))
+		resolver.SetUseCalculator(newUseCalculator(cfg, prof))
+		resolver.SetAutounmask(autounmask || autounmaskWrite)
+		resolver.SetBinary(usePkgOnly)
+		solution, err := resolver.Resolve(expandSets(args, cfg, prof))
 		if err != nil {
 			log.Fatalf("Resolution failed: %v", err)
 		}
+		// Решение, опирающееся на незаписанные изменения конфигурации, не выводится
+		if !applyChanges(resolver.Changes()) {
+			log.Fatalf("Resolution requires the configuration changes above; apply them or rerun with --autounmask-write")
+		}
 
 		if len(solution) == 0 {
 			log.Println("No packages found in solution")
@@ -64,25 +451,21 @@ var resolveCmd = &cobra.Command{
 		}
 
 		fmt.Println("Dependency solution:")
-		for name, pkg := range solution {
-			fmt.Printf("- %s-%s [slot:%s]\n", name, pkg.Version, pkg.Slot.Name)
+		for _, pkg := range resolver.MergeOrder(solution) {
+			fmt.Printf("- [%s] %s [slot:%s]%s\n", resolver.Action(pkg), formatPackage(pkg), pkg.Slot.Name, formatUse(pkg))
 		}
 	},
 }
 
 var installCmd = &cobra.Command{
-	Use:   "install [package...]",
+	Use:   "install [package|@set...]",
 	Short: "Install packages with transaction safety",
 	Args:  cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		// Преобразуем путь в абсолютный
-		absRepoPath, err := filepath.Abs(repoPath)
-		if err != nil {
-			log.Fatalf("Invalid repository path: %v", err)
-		}
+		cfg, prof := loadConfig()
 
 		// Инициализация менеджера снапшотов
-		sm := state.NewSnapshotManager(snapshotDir, fsType)
+		sm := state.NewSnapshotManager(cfg.SnapshotDir, cfg.FSType)
 
 		// Создаем снапшот перед изменениями
 		snapshotID, err := sm.CreateSnapshot("/")
@@ -94,7 +477,7 @@ var installCmd = &cobra.Command{
 		// Разрешаем зависимости
 		var r repo.Repository
 		if !useMockRepo {
-			r, err = repo.NewPortageRepository(absRepoPath)
+			r, err = newRepository(cfg, nil)
 			if err != nil {
 				log.Fatalf("Repository error: %v", err)
 			}
@@ -102,15 +485,27 @@ var installCmd = &cobra.Command{
 			r = repo.NewMockRepository()
 		}
 		resolver := solver.NewResolver(r)
-		solution, err := resolver.Resolve(args)
+		if !useMockRepo {
+			resolver.SetInstalled(vdb.New(vdbPath))
+		}
+		resolver.SetVisibility(newVisibilityFilter(cfg, prof))
+		resolver.SetUseCalculator(newUseCalculator(cfg, prof))
+		resolver.SetAutounmask(autounmask || autounmaskWrite)
+		resolver.SetBinary(usePkgOnly)
+		solution, err := resolver.Resolve(expandSets(args, cfg, prof))
 		if err != nil {
 			log.Fatalf("Dependency resolution failed: %v", err)
 		}
+		if changes := resolver.Changes(); len(changes) > 0 {
+			// Установка продолжается только после перезапуска с обновленной конфигурацией
+			applyChanges(changes)
+			log.Fatalf("Configuration changes are required; rerun install after applying them")
+		}
 
 		// Процесс установки (заглушка)
 		log.Println("Installing packages:")
-		for name, pkg := range solution {
-			log.Printf("- %s-%s (slot: %s)", name, pkg.Version, pkg.Slot)
+		for _, pkg := range resolver.MergeOrder(solution) {
+			log.Printf("- %s (slot: %s)", formatPackage(pkg), pkg.Slot)
 			// Реальная установка будет здесь
 		}
 
@@ -121,17 +516,205 @@ var installCmd = &cobra.Command{
 	},
 }
 
+var regenCmd = &cobra.Command{
+	Use:   "regen",
+	Short: "Generate md5-cache metadata for repository ebuilds",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, _ := loadConfig()
+		pr, err := openRepository(cfg.RepoPath, loadReposConf())
+		if err != nil {
+			log.Fatalf("Repository error: %v", err)
+		}
+		if cacheDir != "" {
+			pr.SetCacheDir(cacheDir)
+		}
+
+		if regenPretend {
+			stale, err := pr.StaleEbuilds()
+			if err != nil {
+				log.Fatalf("Failed to check md5-cache: %v", err)
+			}
+			for _, cpv := range stale {
+				fmt.Println(cpv)
+			}
+			return
+		}
+
+		pr.Generator, err = repo.NewMetadataGenerator(allowNetwork)
+		if err != nil {
+			log.Fatalf("Metadata generator error: %v", err)
+		}
+
+		updated, err := pr.RegenerateMetadata()
+		if err != nil {
+			log.Fatalf("Metadata generation failed: %v", err)
+		}
+		fmt.Printf("Updated %d md5-cache entries\n", updated)
+	},
+}
+
+var eclassUsersCmd = &cobra.Command{
+	Use:   "eclass-users eclass",
+	Short: "List package versions inheriting an eclass",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, _ := loadConfig()
+		pr, err := openRepository(cfg.RepoPath, loadReposConf())
+		if err != nil {
+			log.Fatalf("Repository error: %v", err)
+		}
+
+		users, err := pr.EclassUsers(args[0])
+		if err != nil {
+			log.Fatalf("Failed to scan repository: %v", err)
+		}
+		for _, cpv := range users {
+			fmt.Println(cpv)
+		}
+	},
+}
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Show the effective cascading profile",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, prof := loadConfig()
+		if prof == nil {
+			log.Fatalf("No profile selected")
+		}
+
+		fmt.Println("Profile stack:")
+		for _, node := range prof.Nodes {
+			fmt.Printf("  %s\n", node)
+		}
+		fmt.Printf("ARCH=%q\n", cfg.Arch)
+		fmt.Printf("ACCEPT_KEYWORDS=%q\n", strings.Join(cfg.AcceptKeywords, " "))
+		fmt.Printf("ACCEPT_LICENSE=%q\n", strings.Join(cfg.AcceptLicense, " "))
+		fmt.Printf("USE=%q\n", strings.Join(cfg.Use, " "))
+		fmt.Printf("use.force: %s\n", strings.Join(prof.UseForce, " "))
+		fmt.Printf("use.mask: %s\n", strings.Join(prof.UseMask, " "))
+		fmt.Printf("@system: %s\n", strings.Join(prof.System(), " "))
+	},
+}
+
+var installedCmd = &cobra.Command{
+	Use:   "installed [package|@set...]",
+	Short: "List installed packages from the package database",
+	Run: func(cmd *cobra.Command, args []string) {
+		db := vdb.New(vdbPath)
+
+		var packages []*pkg.Package
+		if len(args) == 0 {
+			var err error
+			if packages, err = db.Installed(); err != nil {
+				log.Fatalf("Failed to read package database: %v", err)
+			}
+		} else {
+			cfg, prof := loadConfig()
+			for _, arg := range expandSets(args, cfg, prof) {
+				atom, aErr := pkg.ParseAtom(arg)
+				if aErr != nil {
+					log.Fatalf("Invalid package argument %s: %v", arg, aErr)
+				}
+				versions, vErr := db.LoadVersions(atom.Name())
+				if vErr != nil {
+					log.Printf("Warning: %v", vErr)
+					continue
+				}
+				for _, p := range versions {
+					if atom.Match(p) {
+						packages = append(packages, p)
+					}
+				}
+			}
+		}
+
+		for _, p := range packages {
+			repository := ""
+			if p.Repository != "" {
+				repository = "::" + p.Repository
+			}
+			fmt.Printf("%s-%s:%s%s%s\n", p.Name, p.Version, p.Slot, repository, formatUse(p))
+		}
+	},
+}
+
+var setsCmd = &cobra.Command{
+	Use:   "sets",
+	Short: "Inspect package sets",
+}
+
+var setsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List available package sets",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, prof := loadConfig()
+		for _, name := range loadSets(cfg, prof).Names() {
+			fmt.Printf("@%s\n", name)
+		}
+	},
+}
+
+var setsShowCmd = &cobra.Command{
+	Use:   "show @set...",
+	Short: "Show the packages of package sets",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, prof := loadConfig()
+		registry := loadSets(cfg, prof)
+		for _, arg := range args {
+			name := strings.TrimPrefix(arg, "@")
+			atoms, err := registry.Expand([]string{"@" + name})
+			if err != nil {
+				log.Fatalf("Failed to expand package sets: %v", err)
+			}
+			fmt.Printf("@%s:\n", name)
+			for _, atom := range atoms {
+				fmt.Printf("  %s\n", atom)
+			}
+		}
+	},
+}
+
 func init() {
+	// Общие флаги конфигурации
+	rootCmd.PersistentFlags().StringVar(&configRoot, "config-root", configRoot, "Portage configuration directory")
+	rootCmd.PersistentFlags().StringVar(&profilePath, "profile", "", "Profile directory (default: <config-root>/make.profile)")
+	rootCmd.PersistentFlags().StringVar(&arch, "arch", "", "System architecture for KEYWORDS filtering (e.g. amd64)")
+	rootCmd.PersistentFlags().StringSliceVar(&acceptKeywords, "accept-keywords", nil, "ACCEPT_KEYWORDS values (e.g. ~amd64)")
+	rootCmd.PersistentFlags().StringVar(&vdbPath, "vdb", vdbPath, "Installed package database directory")
+	rootCmd.PersistentFlags().StringVar(&worldDir, "world-dir", worldDir, "Directory with the world and world_sets files")
+
 	// Флаги для команды install
-	installCmd.Flags().StringVar(&repoPath, "repo", repoPath, "Path to Portage repository")
-	installCmd.Flags().StringVar(&snapshotDir, "snapshot-dir", snapshotDir, "Snapshot directory")
-	installCmd.Flags().StringVar(&fsType, "fs-type", fsType, "Filesystem type (btrfs or zfs)")
-	resolveCmd.Flags().StringVar(&repoPath, "repo", repoPath, "Path to Portage repository")
+	installCmd.Flags().StringVar(&repoPath, "repo", "", "Path to Portage repository (default: PORTDIR from make.conf)")
+	installCmd.Flags().StringVar(&snapshotDir, "snapshot-dir", "", "Snapshot directory (default: GPORTAGE_SNAPSHOT_DIR from make.conf)")
+	installCmd.Flags().StringVar(&fsType, "fs-type", "", "Filesystem type, btrfs or zfs (default: GPORTAGE_FS_TYPE from make.conf)")
+	resolveCmd.Flags().StringVar(&repoPath, "repo", "", "Path to Portage repository (default: PORTDIR from make.conf)")
 	resolveCmd.Flags().BoolVar(&useMockRepo, "mock", false, "Use mock repository")
+	for _, cmd := range []*cobra.Command{resolveCmd, installCmd} {
+		cmd.Flags().BoolVar(&autounmask, "autounmask", false, "Propose keyword, mask, license and USE changes when resolution fails")
+		cmd.Flags().BoolVar(&autounmaskWrite, "autounmask-write", false, "Write proposed autounmask changes to the config files")
+		cmd.Flags().BoolVar(&usePkgOnly, "usepkgonly", false, "Install from binary packages without build-time dependencies")
+	}
+	resolveCmd.Flags().BoolVar(&generateMetadata, "generate-metadata", false, "Generate missing metadata by sourcing ebuilds with bash")
+	for _, cmd := range []*cobra.Command{resolveCmd, regenCmd} {
+		cmd.Flags().BoolVar(&allowNetwork, "allow-network", false, "Generate metadata with network access if network isolation is unavailable")
+	}
+	regenCmd.Flags().StringVar(&repoPath, "repo", "", "Path to Portage repository (default: PORTDIR from make.conf)")
+	regenCmd.Flags().StringVar(&cacheDir, "cache-dir", "", "md5-cache directory (default: <repo>/metadata/md5-cache)")
+	regenCmd.Flags().BoolVar(&regenPretend, "pretend", false, "Only list ebuilds with missing or stale metadata")
+	eclassUsersCmd.Flags().StringVar(&repoPath, "repo", "", "Path to Portage repository (default: PORTDIR from make.conf)")
+	profileCmd.Flags().StringVar(&repoPath, "repo", "", "Path to Portage repository (default: PORTDIR from make.conf)")
+	installedCmd.Flags().StringVar(&repoPath, "repo", "", "Path to Portage repository (default: PORTDIR from make.conf)")
+	setsCmd.PersistentFlags().StringVar(&repoPath, "repo", "", "Path to Portage repository (default: PORTDIR from make.conf)")
+	setsCmd.AddCommand(setsListCmd, setsShowCmd)
 }
 
 func main() {
-	rootCmd.AddCommand(resolveCmd, installCmd)
+	rootCmd.AddCommand(resolveCmd, installCmd, regenCmd, eclassUsersCmd, profileCmd, installedCmd, setsCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
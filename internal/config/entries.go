@@ -0,0 +1,132 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Entry представляет одну строку файла конфигурации Portage (package.mask, package.use, ...)
+type Entry struct {
+	Fields  []string // Поля строки, разделенные пробелами
+	Comment []string // Блок комментариев над записью без символа '#'
+	Source  string   // Файл и номер строки, например package.mask:12
+}
+
+// ReadEntries читает файл конфигурации или каталог с такими файлами.
+// Файлы каталога читаются по алфавиту, скрытые и резервные (~) файлы пропускаются.
+// Отсутствующий путь не считается ошибкой.
+func ReadEntries(path string) ([]Entry, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return readEntriesFile(path)
+	}
+
+	files, err := ListConfigFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, file := range files {
+		fileEntries, err := readEntriesFile(file)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fileEntries...)
+	}
+	return entries, nil
+}
+
+// ListConfigFiles рекурсивно перечисляет файлы каталога конфигурации в алфавитном порядке
+func ListConfigFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") || strings.HasSuffix(e.Name(), "~") {
+			continue
+		}
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+
+	var files []string
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			sub, err := ListConfigFiles(path)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, sub...)
+			continue
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+func readEntriesFile(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	var comment []string
+	inEntries := false // Блок комментариев относится ко всем записям до пустой строки
+
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "":
+			comment = nil
+			inEntries = false
+		case strings.HasPrefix(line, "#"):
+			if inEntries {
+				comment = nil
+				inEntries = false
+			}
+			comment = append(comment, strings.TrimSpace(strings.TrimPrefix(line, "#")))
+		default:
+			// Комментарий в конце строки отбрасывается
+			if i := strings.Index(line, " #"); i >= 0 {
+				line = line[:i]
+			}
+			inEntries = true
+			entries = append(entries, Entry{
+				Fields:  strings.Fields(line),
+				Comment: comment,
+				Source:  fmt.Sprintf("%s:%d", path, lineNo),
+			})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return entries, nil
+}
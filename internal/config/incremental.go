@@ -0,0 +1,48 @@
+package config
+
+import "strings"
+
+// IncrementalVars - переменные make.defaults и make.conf, значения которых накапливаются по слоям
+var IncrementalVars = []string{
+	"USE", "USE_EXPAND", "USE_EXPAND_HIDDEN", "USE_EXPAND_IMPLICIT", "USE_EXPAND_UNPREFIXED",
+	"IUSE_IMPLICIT", "ACCEPT_KEYWORDS", "ACCEPT_LICENSE", "FEATURES",
+	"CONFIG_PROTECT", "CONFIG_PROTECT_MASK", "PROFILE_ONLY_VARIABLES",
+}
+
+// IsIncremental проверяет, является ли переменная инкрементальной
+func IsIncremental(name string) bool {
+	for _, v := range IncrementalVars {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
+
+// StackIncremental применяет инкрементальные правила Portage к списку значений:
+// "-*" очищает накопленные значения, "-value" удаляет значение, остальные добавляются.
+// Повторно добавленное значение переносится в конец списка.
+func StackIncremental(values []string) []string {
+	var result []string
+	for _, v := range values {
+		switch {
+		case v == "-*":
+			result = nil
+		case strings.HasPrefix(v, "-"):
+			result = remove(result, v[1:])
+		default:
+			result = append(remove(result, v), v)
+		}
+	}
+	return result
+}
+
+func remove(values []string, value string) []string {
+	out := values[:0:0]
+	for _, v := range values {
+		if v != value {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Section - секция INI-файла конфигурации (sets.conf, repos.conf)
+type Section struct {
+	Name   string
+	Values map[string]string
+	Source string // Файл и номер строки заголовка секции
+}
+
+// ReadSections читает INI-файл или каталог с такими файлами.
+// Секции с одинаковым именем объединяются, более поздние значения заменяют прежние.
+// Отсутствующий путь не считается ошибкой.
+func ReadSections(path string) ([]*Section, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		if files, err = ListConfigFiles(path); err != nil {
+			return nil, err
+		}
+	}
+
+	var sections []*Section
+	byName := make(map[string]*Section)
+	for _, file := range files {
+		fileSections, err := readSectionsFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range fileSections {
+			if prev, ok := byName[s.Name]; ok {
+				for k, v := range s.Values {
+					prev.Values[k] = v
+				}
+				continue
+			}
+			byName[s.Name] = s
+			sections = append(sections, s)
+		}
+	}
+	return sections, nil
+}
+
+// readSectionsFile разбирает INI-файл: строки "key = value", комментарии '#' и ';',
+// строки с отступом продолжают значение предыдущего ключа
+func readSectionsFile(path string) ([]*Section, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var sections []*Section
+	var current *Section
+	key := ""
+
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			current = &Section{
+				Name:   strings.TrimSpace(line[1 : len(line)-1]),
+				Values: make(map[string]string),
+				Source: fmt.Sprintf("%s:%d", path, lineNo),
+			}
+			sections = append(sections, current)
+			key = ""
+		case current == nil:
+			return nil, fmt.Errorf("%s:%d: value outside of a section", path, lineNo)
+		case key != "" && (raw[0] == ' ' || raw[0] == '\t'):
+			current.Values[key] = strings.TrimSpace(current.Values[key] + "\n" + line)
+		default:
+			k, v, found := strings.Cut(line, "=")
+			if !found {
+				return nil, fmt.Errorf("%s:%d: expected key = value", path, lineNo)
+			}
+			key = strings.ToLower(strings.TrimSpace(k))
+			current.Values[key] = strings.TrimSpace(v)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return sections, nil
+}
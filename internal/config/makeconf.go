@@ -0,0 +1,142 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Значения по умолчанию для переменных, не заданных ни профилем, ни make.conf
+const (
+	DefaultRepoPath    = "/var/db/repos/gentoo"
+	DefaultSnapshotDir = "/.snapshots"
+	DefaultFSType      = "btrfs"
+
+	// DefaultAcceptLicense - значение ACCEPT_LICENSE из make.globals Portage,
+	// на которое накладываются профиль и make.conf
+	DefaultAcceptLicense = "-* @FREE"
+)
+
+// Config - эффективная конфигурация: make.defaults профиля и make.conf поверх него
+type Config struct {
+	Root        string              // Каталог конфигурации (обычно /etc/portage)
+	Vars        map[string]string   // Все переменные с учетом инкрементальных
+	Incremental map[string][]string // Значения инкрементальных переменных по слоям до объединения
+	Local       map[string][]string // Значения инкрементальных переменных из make.conf и командной строки
+
+	Use            []string // USE
+	AcceptKeywords []string // ACCEPT_KEYWORDS
+	AcceptLicense  []string // ACCEPT_LICENSE
+	Arch           string   // ARCH
+	RepoPath       string   // PORTDIR
+	SnapshotDir    string   // GPORTAGE_SNAPSHOT_DIR
+	FSType         string   // GPORTAGE_FS_TYPE (btrfs или zfs)
+}
+
+// Load читает <root>/make.conf (файл или каталог) поверх переменных профиля.
+// profileVars и profileIncremental могут быть nil, если профиль не выбран.
+func Load(root string, profileVars map[string]string, profileIncremental map[string][]string) (*Config, error) {
+	c := &Config{
+		Root:        root,
+		Vars:        make(map[string]string),
+		Incremental: make(map[string][]string),
+		Local:       make(map[string][]string),
+	}
+	for name, value := range profileVars {
+		c.Vars[name] = value
+	}
+	c.Incremental["ACCEPT_LICENSE"] = strings.Fields(DefaultAcceptLicense)
+	for name, values := range profileIncremental {
+		c.Incremental[name] = append(c.Incremental[name], values...)
+	}
+
+	files, err := makeConfFiles(filepath.Join(root, "make.conf"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if err := c.parseMakeConf(file); err != nil {
+			return nil, err
+		}
+	}
+
+	c.Use = c.stacked("USE")
+	c.AcceptKeywords = c.stacked("ACCEPT_KEYWORDS")
+	c.AcceptLicense = c.stacked("ACCEPT_LICENSE")
+	c.Arch = c.Vars["ARCH"]
+	c.RepoPath = c.varOr("PORTDIR", DefaultRepoPath)
+	c.SnapshotDir = c.varOr("GPORTAGE_SNAPSHOT_DIR", DefaultSnapshotDir)
+	c.FSType = c.varOr("GPORTAGE_FS_TYPE", DefaultFSType)
+	return c, nil
+}
+
+// makeConfFiles возвращает make.conf или файлы каталога make.conf в порядке чтения
+func makeConfFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	return ListConfigFiles(path)
+}
+
+// parseMakeConf разбирает один файл make.conf; подстановки видят уже известные переменные
+func (c *Config) parseMakeConf(path string) error {
+	sv := NewShellVars(func(name string) (string, bool) {
+		v, ok := c.Vars[name]
+		return v, ok
+	})
+	if err := sv.ParseFile(path); err != nil {
+		return err
+	}
+
+	for name, value := range sv.Vars {
+		if IsIncremental(name) {
+			c.addLayer(name, strings.Fields(value))
+			continue
+		}
+		c.Vars[name] = value
+	}
+	return nil
+}
+
+func (c *Config) addLayer(name string, values []string) {
+	c.Incremental[name] = append(c.Incremental[name], values...)
+	c.Local[name] = append(c.Local[name], values...)
+	c.Vars[name] = strings.Join(c.stacked(name), " ")
+}
+
+func (c *Config) stacked(name string) []string {
+	return StackIncremental(c.Incremental[name])
+}
+
+func (c *Config) varOr(name, def string) string {
+	if v := c.Vars[name]; v != "" {
+		return v
+	}
+	return def
+}
+
+// Var возвращает значение переменной конфигурации
+func (c *Config) Var(name string) string {
+	return c.Vars[name]
+}
+
+// AddIncremental добавляет слой значений инкрементальной переменной (например, из командной строки)
+func (c *Config) AddIncremental(name string, values []string) {
+	c.addLayer(name, values)
+
+	switch name {
+	case "USE":
+		c.Use = c.stacked(name)
+	case "ACCEPT_KEYWORDS":
+		c.AcceptKeywords = c.stacked(name)
+	case "ACCEPT_LICENSE":
+		c.AcceptLicense = c.stacked(name)
+	}
+}
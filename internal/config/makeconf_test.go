@@ -0,0 +1,147 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestStackIncremental(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   []string
+	}{
+		{nil, nil},
+		{[]string{"a", "b"}, []string{"a", "b"}},
+		{[]string{"a", "b", "-a"}, []string{"b"}},
+		{[]string{"a", "b", "-*", "c"}, []string{"c"}},
+		{[]string{"a", "b", "a"}, []string{"b", "a"}},
+		{[]string{"-a", "a"}, []string{"a"}},
+		{[]string{"-*", "@FREE", "-@FREE", "MIT"}, []string{"MIT"}},
+	}
+	for _, tt := range tests {
+		if got := StackIncremental(tt.values); !slices.Equal(got, tt.want) {
+			t.Errorf("StackIncremental(%q) = %q, want %q", tt.values, got, tt.want)
+		}
+	}
+}
+
+// writeFiles создает файлы относительно root
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadMakeConf(t *testing.T) {
+	profileVars := map[string]string{"ARCH": "amd64", "USE": "ssl zlib", "CHOST": "x86_64-pc-linux-gnu"}
+	profileIncremental := map[string][]string{
+		"USE":             {"ssl", "zlib"},
+		"ACCEPT_KEYWORDS": {"amd64"},
+	}
+
+	tests := []struct {
+		name     string
+		files    map[string]string
+		use      []string
+		keywords []string
+		license  []string
+		vars     map[string]string
+	}{
+		{
+			name:     "no make.conf",
+			use:      []string{"ssl", "zlib"},
+			keywords: []string{"amd64"},
+			license:  []string{"@FREE"},
+			vars:     map[string]string{"PORTDIR": "", "CHOST": "x86_64-pc-linux-gnu"},
+		},
+		{
+			name: "file",
+			files: map[string]string{"make.conf": `USE="-zlib X"
+ACCEPT_KEYWORDS="~${ARCH}"
+ACCEPT_LICENSE="-* MIT"
+CFLAGS="-O2 -march=native"
+PORTDIR="/srv/gentoo"
+`},
+			use:      []string{"ssl", "X"},
+			keywords: []string{"amd64", "~amd64"},
+			license:  []string{"MIT"},
+			vars:     map[string]string{"CFLAGS": "-O2 -march=native", "PORTDIR": "/srv/gentoo", "USE": "ssl X"},
+		},
+		{
+			name: "directory",
+			files: map[string]string{
+				"make.conf/00-base":  "USE=\"-* gtk\"\nCFLAGS=\"-O2\"\n",
+				"make.conf/10-local": "USE=\"qt5\"\nCXXFLAGS=\"${CFLAGS} -g\"\n",
+			},
+			use:      []string{"gtk", "qt5"},
+			keywords: []string{"amd64"},
+			license:  []string{"@FREE"},
+			vars:     map[string]string{"CXXFLAGS": "-O2 -g"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, tt.files)
+
+			c, err := Load(root, profileVars, profileIncremental)
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if !slices.Equal(c.Use, tt.use) {
+				t.Errorf("Use = %q, want %q", c.Use, tt.use)
+			}
+			if !slices.Equal(c.AcceptKeywords, tt.keywords) {
+				t.Errorf("AcceptKeywords = %q, want %q", c.AcceptKeywords, tt.keywords)
+			}
+			if !slices.Equal(c.AcceptLicense, tt.license) {
+				t.Errorf("AcceptLicense = %q, want %q", c.AcceptLicense, tt.license)
+			}
+			for name, value := range tt.vars {
+				if c.Var(name) != value {
+					t.Errorf("%s = %q, want %q", name, c.Var(name), value)
+				}
+			}
+			if c.Arch != "amd64" {
+				t.Errorf("Arch = %q, want amd64", c.Arch)
+			}
+		})
+	}
+}
+
+func TestLoadDefaultsAndCommandLine(t *testing.T) {
+	c, err := Load(t.TempDir(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.RepoPath != DefaultRepoPath || c.SnapshotDir != DefaultSnapshotDir || c.FSType != DefaultFSType {
+		t.Errorf("defaults = %q %q %q", c.RepoPath, c.SnapshotDir, c.FSType)
+	}
+
+	// Значения из командной строки накладываются последним слоем
+	c.AddIncremental("USE", []string{"ssl", "test"})
+	c.AddIncremental("USE", []string{"-test"})
+	if !slices.Equal(c.Use, []string{"ssl"}) || c.Var("USE") != "ssl" {
+		t.Errorf("Use = %q, USE = %q, want ssl", c.Use, c.Var("USE"))
+	}
+	if !slices.Equal(c.Local["USE"], []string{"ssl", "test", "-test"}) {
+		t.Errorf("Local[USE] = %q", c.Local["USE"])
+	}
+}
+
+func TestLoadMakeConfError(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"make.conf": "USE=\"ssl\nCFLAGS=-O2\n"})
+	if _, err := Load(root, nil, nil); err == nil {
+		t.Error("Load() with unterminated quote: want error")
+	}
+}
@@ -0,0 +1,315 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ShellVars разбирает файлы в формате make.defaults / make.conf:
+// присваивания NAME=value с кавычками bash и подстановкой ${VAR} и $VAR
+type ShellVars struct {
+	Vars   map[string]string                // Переменные, заданные разобранными файлами
+	Lookup func(name string) (string, bool) // Источник значений для переменных, не заданных в файлах
+}
+
+func NewShellVars(lookup func(name string) (string, bool)) *ShellVars {
+	return &ShellVars{
+		Vars:   make(map[string]string),
+		Lookup: lookup,
+	}
+}
+
+// maxSourceDepth ограничивает вложенность source, защищая от циклов
+const maxSourceDepth = 16
+
+// ParseFile разбирает файл; отсутствующий файл не считается ошибкой
+func (sv *ShellVars) ParseFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return sv.parseFile(path, string(content), 0)
+}
+
+// Parse разбирает содержимое файла, добавляя переменные в sv.Vars.
+// Относительные пути в source отсчитываются от текущего каталога.
+func (sv *ShellVars) Parse(content string) error {
+	p := &shellParser{src: content, sv: sv, line: 1}
+	return p.parse()
+}
+
+func (sv *ShellVars) parseFile(path, content string, depth int) error {
+	p := &shellParser{src: content, sv: sv, line: 1, dir: filepath.Dir(path), depth: depth}
+	if err := p.parse(); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// get возвращает значение переменной для подстановки
+func (sv *ShellVars) get(name string) string {
+	if v, ok := sv.Vars[name]; ok {
+		return v
+	}
+	if sv.Lookup != nil {
+		if v, ok := sv.Lookup(name); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+type shellParser struct {
+	src   string
+	pos   int
+	line  int
+	sv    *ShellVars
+	dir   string // Каталог разбираемого файла для относительных путей source
+	depth int    // Уровень вложенности source
+}
+
+func (p *shellParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *shellParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *shellParser) peek() byte {
+	return p.src[p.pos]
+}
+
+func (p *shellParser) next() byte {
+	c := p.src[p.pos]
+	p.pos++
+	if c == '\n' {
+		p.line++
+	}
+	return c
+}
+
+// skipBlank пропускает пробелы, пустые строки, комментарии и разделители ';'
+func (p *shellParser) skipBlank() {
+	for !p.eof() {
+		switch c := p.peek(); {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ';':
+			p.next()
+		case c == '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.next()
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *shellParser) parse() error {
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return nil
+		}
+
+		word, err := p.readWord()
+		if err != nil {
+			return err
+		}
+
+		if word == "export" {
+			continue
+		}
+		if word == "source" || word == "." {
+			if err := p.source(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name, value, isAssign := strings.Cut(word, "=")
+		if !isAssign || !isVarName(name) {
+			return p.errorf("unsupported statement %q", word)
+		}
+		p.sv.Vars[name] = value
+	}
+}
+
+// source разбирает файл, указанный после команды source (или ".")
+func (p *shellParser) source() error {
+	p.skipSpaces()
+	path, err := p.readWord()
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return p.errorf("source: missing file name")
+	}
+	if p.depth >= maxSourceDepth {
+		return p.errorf("source: nesting too deep at %s", path)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(p.dir, path)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return p.errorf("source: %v", err)
+	}
+	return p.sv.parseFile(path, string(content), p.depth+1)
+}
+
+// skipSpaces пропускает пробелы в пределах строки
+func (p *shellParser) skipSpaces() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.next()
+	}
+}
+
+// readWord читает одно слово shell с раскрытием кавычек и подстановкой переменных
+func (p *shellParser) readWord() (string, error) {
+	var sb strings.Builder
+	for !p.eof() {
+		c := p.peek()
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ';':
+			return sb.String(), nil
+		case c == '"':
+			p.next()
+			if err := p.readDoubleQuoted(&sb); err != nil {
+				return "", err
+			}
+		case c == '\'':
+			p.next()
+			start := p.pos
+			for !p.eof() && p.peek() != '\'' {
+				p.next()
+			}
+			if p.eof() {
+				return "", p.errorf("unterminated single quote")
+			}
+			sb.WriteString(p.src[start:p.pos])
+			p.next()
+		case c == '\\':
+			p.next()
+			if p.eof() {
+				return sb.String(), nil
+			}
+			// Экранированный перевод строки продолжает слово
+			if e := p.next(); e != '\n' {
+				sb.WriteByte(e)
+			}
+		case c == '$':
+			p.next()
+			value, err := p.readExpansion()
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(value)
+		default:
+			sb.WriteByte(p.next())
+		}
+	}
+	return sb.String(), nil
+}
+
+func (p *shellParser) readDoubleQuoted(sb *strings.Builder) error {
+	for !p.eof() {
+		c := p.next()
+		switch c {
+		case '"':
+			return nil
+		case '\\':
+			if p.eof() {
+				return p.errorf("unterminated double quote")
+			}
+			e := p.next()
+			switch e {
+			case '"', '\\', '$', '`':
+				sb.WriteByte(e)
+			case '\n':
+				// Продолжение строки
+			default:
+				sb.WriteByte('\\')
+				sb.WriteByte(e)
+			}
+		case '$':
+			value, err := p.readExpansion()
+			if err != nil {
+				return err
+			}
+			sb.WriteString(value)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return p.errorf("unterminated double quote")
+}
+
+// readExpansion разбирает ${VAR}, ${VAR:-default} или $VAR после символа '$'
+func (p *shellParser) readExpansion() (string, error) {
+	if p.eof() {
+		return "$", nil
+	}
+
+	if p.peek() == '{' {
+		p.next()
+		start := p.pos
+		for !p.eof() && p.peek() != '}' {
+			p.next()
+		}
+		if p.eof() {
+			return "", p.errorf("unterminated ${")
+		}
+		expr := p.src[start:p.pos]
+		p.next()
+
+		if name, def, ok := strings.Cut(expr, ":-"); ok {
+			if v := p.sv.get(name); v != "" {
+				return v, nil
+			}
+			return def, nil
+		}
+		if !isVarName(expr) {
+			return "", p.errorf("unsupported expansion ${%s}", expr)
+		}
+		return p.sv.get(expr), nil
+	}
+
+	start := p.pos
+	for !p.eof() && isVarChar(p.peek(), p.pos == start) {
+		p.next()
+	}
+	if start == p.pos {
+		return "$", nil
+	}
+	return p.sv.get(p.src[start:p.pos]), nil
+}
+
+func isVarName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isVarChar(s[i], i == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func isVarChar(c byte, first bool) bool {
+	switch {
+	case c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+		return true
+	case c >= '0' && c <= '9':
+		return !first
+	default:
+		return false
+	}
+}
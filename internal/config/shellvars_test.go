@@ -0,0 +1,116 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestShellVarsParse(t *testing.T) {
+	env := map[string]string{"ARCH": "amd64", "EMPTY": ""}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+	}{
+		{"plain", "A=1\nB=two", map[string]string{"A": "1", "B": "two"}},
+		{"double quotes", `CFLAGS="-O2 -pipe"`, map[string]string{"CFLAGS": "-O2 -pipe"}},
+		{"single quotes", `A='${ARCH} $x'`, map[string]string{"A": "${ARCH} $x"}},
+		{"braced expansion", `A="${ARCH}-linux"`, map[string]string{"A": "amd64-linux"}},
+		{"bare expansion", `A=$ARCH/x`, map[string]string{"A": "amd64/x"}},
+		{"own variables", "A=1\nB=\"$A ${A}2\"", map[string]string{"A": "1", "B": "1 12"}},
+		{"redefinition", "USE=\"a\"\nUSE=\"${USE} b\"", map[string]string{"USE": "a b"}},
+		{"default", `A="${EMPTY:-x} ${UNSET:-y} ${ARCH:-z}"`, map[string]string{"A": "x y amd64"}},
+		{"unset variable", `A="[$UNSET]"`, map[string]string{"A": "[]"}},
+		{"comments and export", "# comment\nexport A=1 # trailing\n\n", map[string]string{"A": "1"}},
+		{"semicolons", "A=1; B=2", map[string]string{"A": "1", "B": "2"}},
+		{"line continuation", "A=\"one \\\ntwo\"\nB=x\\\ny", map[string]string{"A": "one two", "B": "xy"}},
+		{"escapes", `A="\"q\" \$ARCH \n"`, map[string]string{"A": `"q" $ARCH \n`}},
+		{"multiline value", "A=\"one\ntwo\"", map[string]string{"A": "one\ntwo"}},
+		{"literal dollar", `A="cost $ 5" B=$`, map[string]string{"A": "cost $ 5", "B": "$"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sv := NewShellVars(lookup)
+			if err := sv.Parse(tt.content); err != nil {
+				t.Fatalf("Parse() error: %v", err)
+			}
+			if len(sv.Vars) != len(tt.want) {
+				t.Errorf("Vars = %q, want %q", sv.Vars, tt.want)
+			}
+			for name, value := range tt.want {
+				if sv.Vars[name] != value {
+					t.Errorf("%s = %q, want %q", name, sv.Vars[name], value)
+				}
+			}
+		})
+	}
+}
+
+func TestShellVarsErrors(t *testing.T) {
+	tests := []struct {
+		content string
+		wantErr string
+	}{
+		{`A="unterminated`, "line 1: unterminated double quote"},
+		{"A=1\nB='x", "line 2: unterminated single quote"},
+		{"A=${B", "unterminated ${"},
+		{"A=${B/x/y}", "unsupported expansion"},
+		{"echo hello", `unsupported statement "echo"`},
+		{"1A=x", "unsupported statement"},
+		{"source", "missing file name"},
+	}
+	for _, tt := range tests {
+		err := NewShellVars(nil).Parse(tt.content)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("Parse(%q) error = %v, want %q", tt.content, err, tt.wantErr)
+		}
+	}
+}
+
+func TestShellVarsSource(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"make.conf":         "A=1\nsource conf.d/extra.conf\nC=\"$B-3\"\n",
+		"conf.d/extra.conf": "B=\"$A-2\"\n. ../loop.conf\n",
+		"loop.conf":         "D=4\n",
+		"self.conf":         "source self.conf\n",
+		"missing.conf":      "source nonexistent.conf\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sv := NewShellVars(nil)
+	if err := sv.ParseFile(filepath.Join(dir, "make.conf")); err != nil {
+		t.Fatalf("ParseFile() error: %v", err)
+	}
+	want := map[string]string{"A": "1", "B": "1-2", "C": "1-2-3", "D": "4"}
+	for name, value := range want {
+		if sv.Vars[name] != value {
+			t.Errorf("%s = %q, want %q", name, sv.Vars[name], value)
+		}
+	}
+
+	if err := NewShellVars(nil).ParseFile(filepath.Join(dir, "self.conf")); err == nil || !strings.Contains(err.Error(), "nesting too deep") {
+		t.Errorf("recursive source error = %v, want nesting too deep", err)
+	}
+	if err := NewShellVars(nil).ParseFile(filepath.Join(dir, "missing.conf")); err == nil || !strings.Contains(err.Error(), "missing.conf") {
+		t.Errorf("missing source error = %v, want error naming missing.conf", err)
+	}
+	if err := NewShellVars(nil).ParseFile(filepath.Join(dir, "absent.conf")); err != nil {
+		t.Errorf("ParseFile(absent) error = %v, want nil", err)
+	}
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// AutounmaskFile - имя файла для новых записей, если файл конфигурации является каталогом
+const AutounmaskFile = "zz-autounmask"
+
+// AppendEntry дописывает строку entry с комментарием в файл конфигурации.
+// Если path - каталог, запись добавляется в файл zz-autounmask внутри него.
+// Строка, которая уже есть в файле, повторно не добавляется.
+func AppendEntry(path, comment, entry string) error {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, AutounmaskFile)
+	}
+
+	existing, err := ReadEntries(path)
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(entry)
+	for _, e := range existing {
+		if strings.Join(e.Fields, " ") == strings.Join(fields, " ") {
+			return nil
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	// Пустая строка отделяет новую запись, чтобы ее комментарий не относился к предыдущим
+	var sb strings.Builder
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		sb.WriteString("\n")
+	}
+	if comment != "" {
+		fmt.Fprintf(&sb, "# %s\n", comment)
+	}
+	fmt.Fprintf(&sb, "%s\n", entry)
+	if _, err := f.WriteString(sb.String()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
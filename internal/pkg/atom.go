@@ -0,0 +1,482 @@
+package pkg
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// BlockerType определяет тип блокировки атома
+type BlockerType int
+
+const (
+	BlockerNone   BlockerType = iota
+	BlockerWeak               // !cat/pkg
+	BlockerStrong             // !!cat/pkg
+)
+
+// SlotOperator определяет оператор слота в атоме
+type SlotOperator int
+
+const (
+	SlotOpNone  SlotOperator = iota
+	SlotOpEqual              // :=  или :slot=
+	SlotOpAny                // :*
+)
+
+// UseDepType определяет вид USE-зависимости (PMS, раздел 8.3.4)
+type UseDepType int
+
+const (
+	UseDepEnabled    UseDepType = iota // [flag]
+	UseDepDisabled                     // [-flag]
+	UseDepEqual                        // [flag=]
+	UseDepNotEqual                     // [!flag=]
+	UseDepIfEnabled                    // [flag?]
+	UseDepIfDisabled                   // [!flag?]
+)
+
+// UseDepDefault определяет значение флага по умолчанию, если его нет в IUSE зависимости
+type UseDepDefault int
+
+const (
+	UseDefaultNone     UseDepDefault = iota
+	UseDefaultEnabled                // flag(+)
+	UseDefaultDisabled               // flag(-)
+)
+
+// UseDep представляет одну USE-зависимость атома
+type UseDep struct {
+	Flag    string
+	Type    UseDepType
+	Default UseDepDefault
+}
+
+func (u UseDep) String() string {
+	flag := u.Flag
+	switch u.Default {
+	case UseDefaultEnabled:
+		flag += "(+)"
+	case UseDefaultDisabled:
+		flag += "(-)"
+	}
+
+	switch u.Type {
+	case UseDepDisabled:
+		return "-" + flag
+	case UseDepEqual:
+		return flag + "="
+	case UseDepNotEqual:
+		return "!" + flag + "="
+	case UseDepIfEnabled:
+		return flag + "?"
+	case UseDepIfDisabled:
+		return "!" + flag + "?"
+	default:
+		return flag
+	}
+}
+
+// Requirement раскрывает USE-зависимость для набора флагов родительского пакета:
+// возвращает требуемое состояние флага зависимости и признак того, что требование действует
+func (u UseDep) Requirement(parentUse map[string]bool) (enabled bool, applies bool) {
+	switch u.Type {
+	case UseDepEnabled:
+		return true, true
+	case UseDepDisabled:
+		return false, true
+	case UseDepEqual:
+		return parentUse[u.Flag], true
+	case UseDepNotEqual:
+		return !parentUse[u.Flag], true
+	case UseDepIfEnabled:
+		return true, parentUse[u.Flag]
+	default: // UseDepIfDisabled
+		return false, !parentUse[u.Flag]
+	}
+}
+
+// ChildState возвращает состояние флага в пакете-зависимости.
+// Если флага нет в IUSE, используется значение по умолчанию (+)/(-); без него known = false.
+func (u UseDep) ChildState(p *Package) (enabled bool, known bool) {
+	if state, ok := p.UseFlags[u.Flag]; ok {
+		return state, true
+	}
+	switch u.Default {
+	case UseDefaultEnabled:
+		return true, true
+	case UseDefaultDisabled:
+		return false, true
+	}
+	return false, false
+}
+
+// Atom представляет атом зависимости в формате PMS:
+// [!|!!][op]category/package[-version][*][:slot[/subslot]][=|*][::repo][[use,deps]]
+type Atom struct {
+	Blocker    BlockerType
+	Category   string
+	Package    string
+	Version    *VersionConstraint // nil, если версия не указана
+	Slot       string
+	Subslot    string
+	SlotOp     SlotOperator
+	UseDeps    []UseDep
+	Repository string
+}
+
+var (
+	categoryRe    = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9+_.-]*$`)
+	packageNameRe = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9+_-]*$`)
+	slotNameRe    = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9+_.-]*$`)
+	useFlagRe     = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9+_@-]*$`)
+)
+
+// ParseAtom разбирает строку атома зависимости
+func ParseAtom(s string) (*Atom, error) {
+	a := &Atom{}
+	rest := s
+
+	// Блокировки
+	if strings.HasPrefix(rest, "!!") {
+		a.Blocker = BlockerStrong
+		rest = rest[2:]
+	} else if strings.HasPrefix(rest, "!") {
+		a.Blocker = BlockerWeak
+		rest = rest[1:]
+	}
+
+	// USE-зависимости в конце атома
+	if strings.HasSuffix(rest, "]") {
+		open := strings.Index(rest, "[")
+		if open < 0 {
+			return nil, fmt.Errorf("invalid atom %q: unbalanced USE dependency brackets", s)
+		}
+		deps, err := parseUseDeps(rest[open+1 : len(rest)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid atom %q: %w", s, err)
+		}
+		a.UseDeps = deps
+		rest = rest[:open]
+	}
+
+	// Ограничение репозитория
+	if before, repo, found := strings.Cut(rest, "::"); found {
+		if !packageNameRe.MatchString(repo) {
+			return nil, fmt.Errorf("invalid atom %q: bad repository name %q", s, repo)
+		}
+		a.Repository = repo
+		rest = before
+	}
+
+	// Слот
+	if before, slot, found := strings.Cut(rest, ":"); found {
+		if err := a.parseSlot(slot); err != nil {
+			return nil, fmt.Errorf("invalid atom %q: %w", s, err)
+		}
+		rest = before
+	}
+
+	// Оператор версии
+	op, opStr := parseAtomOperator(rest)
+	rest = rest[len(opStr):]
+
+	glob := false
+	if strings.HasSuffix(rest, "*") {
+		if op != OpEqual {
+			return nil, fmt.Errorf("invalid atom %q: '*' is only allowed with '='", s)
+		}
+		glob = true
+		rest = strings.TrimSuffix(rest, "*")
+	}
+
+	category, name, found := strings.Cut(rest, "/")
+	if !found {
+		return nil, fmt.Errorf("invalid atom %q: missing category", s)
+	}
+	if !categoryRe.MatchString(category) {
+		return nil, fmt.Errorf("invalid atom %q: bad category %q", s, category)
+	}
+	a.Category = category
+
+	if opStr != "" {
+		pn, version, ok := SplitPackageVersion(name)
+		if !ok {
+			return nil, fmt.Errorf("invalid atom %q: operator %q requires a version", s, opStr)
+		}
+		if op == OpApproximate && strings.Contains(version, "-r") {
+			return nil, fmt.Errorf("invalid atom %q: '~' does not allow a revision", s)
+		}
+		name = pn
+		if glob {
+			op = OpEqualGlob
+		}
+		a.Version = NewVersionConstraint(op, version)
+	} else if glob {
+		return nil, fmt.Errorf("invalid atom %q: '*' without version operator", s)
+	}
+
+	if !packageNameRe.MatchString(name) {
+		return nil, fmt.Errorf("invalid atom %q: bad package name %q", s, name)
+	}
+	if _, _, ok := SplitPackageVersion(name); ok {
+		return nil, fmt.Errorf("invalid atom %q: version without operator", s)
+	}
+	a.Package = name
+
+	return a, nil
+}
+
+// parseAtomOperator определяет оператор версии в начале атома
+func parseAtomOperator(s string) (VersionOperator, string) {
+	for _, o := range []struct {
+		str string
+		op  VersionOperator
+	}{
+		{">=", OpGreaterEqual},
+		{"<=", OpLessEqual},
+		{">", OpGreater},
+		{"<", OpLess},
+		{"=", OpEqual},
+		{"~", OpApproximate},
+	} {
+		if strings.HasPrefix(s, o.str) {
+			return o.op, o.str
+		}
+	}
+	return OpEqual, ""
+}
+
+// SplitPackageVersion отделяет версию от имени пакета: foo-bar-1.0-r1 -> foo-bar, 1.0-r1
+func SplitPackageVersion(s string) (name, version string, ok bool) {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '-' && IsValidVersion(s[i+1:]) {
+			return s[:i], s[i+1:], true
+		}
+	}
+	return s, "", false
+}
+
+func (a *Atom) parseSlot(slot string) error {
+	switch {
+	case slot == "*":
+		a.SlotOp = SlotOpAny
+		return nil
+	case slot == "=":
+		a.SlotOp = SlotOpEqual
+		return nil
+	case strings.HasSuffix(slot, "="):
+		a.SlotOp = SlotOpEqual
+		slot = strings.TrimSuffix(slot, "=")
+	}
+
+	name, subslot, hasSub := strings.Cut(slot, "/")
+	if !slotNameRe.MatchString(name) {
+		return fmt.Errorf("bad slot %q", slot)
+	}
+	if hasSub && !slotNameRe.MatchString(subslot) {
+		return fmt.Errorf("bad subslot %q", slot)
+	}
+	a.Slot = name
+	a.Subslot = subslot
+	return nil
+}
+
+func parseUseDeps(s string) ([]UseDep, error) {
+	var deps []UseDep
+	for _, item := range strings.Split(s, ",") {
+		dep, err := parseUseDep(strings.TrimSpace(item))
+		if err != nil {
+			return nil, err
+		}
+		deps = append(deps, dep)
+	}
+	return deps, nil
+}
+
+func parseUseDep(s string) (UseDep, error) {
+	dep := UseDep{Type: UseDepEnabled}
+	flag := s
+
+	switch {
+	case strings.HasPrefix(flag, "!") && strings.HasSuffix(flag, "="):
+		dep.Type = UseDepNotEqual
+		flag = flag[1 : len(flag)-1]
+	case strings.HasPrefix(flag, "!") && strings.HasSuffix(flag, "?"):
+		dep.Type = UseDepIfDisabled
+		flag = flag[1 : len(flag)-1]
+	case strings.HasPrefix(flag, "-"):
+		dep.Type = UseDepDisabled
+		flag = flag[1:]
+	case strings.HasSuffix(flag, "="):
+		dep.Type = UseDepEqual
+		flag = flag[:len(flag)-1]
+	case strings.HasSuffix(flag, "?"):
+		dep.Type = UseDepIfEnabled
+		flag = flag[:len(flag)-1]
+	}
+
+	if strings.HasSuffix(flag, "(+)") {
+		dep.Default = UseDefaultEnabled
+		flag = strings.TrimSuffix(flag, "(+)")
+	} else if strings.HasSuffix(flag, "(-)") {
+		dep.Default = UseDefaultDisabled
+		flag = strings.TrimSuffix(flag, "(-)")
+	}
+
+	if !useFlagRe.MatchString(flag) {
+		return UseDep{}, fmt.Errorf("bad USE dependency %q", s)
+	}
+	dep.Flag = flag
+	return dep, nil
+}
+
+// Name возвращает полное имя пакета category/package
+func (a *Atom) Name() string {
+	return a.Category + "/" + a.Package
+}
+
+// String возвращает строковое представление атома, пригодное для повторного разбора
+func (a *Atom) String() string {
+	var sb strings.Builder
+
+	switch a.Blocker {
+	case BlockerWeak:
+		sb.WriteString("!")
+	case BlockerStrong:
+		sb.WriteString("!!")
+	}
+
+	if a.Version != nil {
+		switch a.Version.Operator {
+		case OpEqual, OpEqualGlob:
+			sb.WriteString("=")
+		case OpGreater:
+			sb.WriteString(">")
+		case OpGreaterEqual:
+			sb.WriteString(">=")
+		case OpLess:
+			sb.WriteString("<")
+		case OpLessEqual:
+			sb.WriteString("<=")
+		case OpApproximate:
+			sb.WriteString("~")
+		}
+	}
+
+	sb.WriteString(a.Name())
+
+	if a.Version != nil {
+		sb.WriteString("-" + a.Version.Version)
+		if a.Version.Operator == OpEqualGlob {
+			sb.WriteString("*")
+		}
+	}
+
+	switch {
+	case a.SlotOp == SlotOpAny:
+		sb.WriteString(":*")
+	case a.Slot != "" || a.SlotOp == SlotOpEqual:
+		sb.WriteString(":" + a.Slot)
+		if a.Subslot != "" {
+			sb.WriteString("/" + a.Subslot)
+		}
+		if a.SlotOp == SlotOpEqual {
+			sb.WriteString("=")
+		}
+	}
+
+	if a.Repository != "" {
+		sb.WriteString("::" + a.Repository)
+	}
+
+	if len(a.UseDeps) > 0 {
+		deps := make([]string, len(a.UseDeps))
+		for i, d := range a.UseDeps {
+			deps[i] = d.String()
+		}
+		sb.WriteString("[" + strings.Join(deps, ",") + "]")
+	}
+
+	return sb.String()
+}
+
+// Equal сообщает, задают ли два атома одно и то же ограничение независимо от
+// написания: версии сравниваются по PMS, порядок USE-зависимостей не важен
+func (a *Atom) Equal(b *Atom) bool {
+	if a.Blocker != b.Blocker || a.Name() != b.Name() ||
+		a.Slot != b.Slot || a.Subslot != b.Subslot || a.SlotOp != b.SlotOp ||
+		a.Repository != b.Repository {
+		return false
+	}
+
+	switch {
+	case a.Version == nil || b.Version == nil:
+		if a.Version != b.Version {
+			return false
+		}
+	case a.Version.Operator != b.Version.Operator ||
+		CompareVersions(a.Version.Version, b.Version.Version) != 0:
+		return false
+	}
+
+	if len(a.UseDeps) != len(b.UseDeps) {
+		return false
+	}
+	deps := make(map[UseDep]bool, len(a.UseDeps))
+	for _, d := range a.UseDeps {
+		deps[d] = true
+	}
+	for _, d := range b.UseDeps {
+		if !deps[d] {
+			return false
+		}
+	}
+	return true
+}
+
+// Match проверяет, подходит ли пакет под атом (без учета блокировки и USE-зависимостей)
+func (a *Atom) Match(p *Package) bool {
+	if p.Name != a.Name() {
+		return false
+	}
+	if !a.Version.Satisfies(p.Version) {
+		return false
+	}
+	if a.Slot != "" && p.Slot.Name != a.Slot {
+		return false
+	}
+	if a.Subslot != "" && p.Slot.Subslot != a.Subslot {
+		return false
+	}
+	if a.Repository != "" && p.Repository != a.Repository {
+		return false
+	}
+	return true
+}
+
+// MatchUse проверяет USE-зависимости атома для пакета p, требуемого пакетом с флагами parentUse
+func (a *Atom) MatchUse(p *Package, parentUse map[string]bool) bool {
+	for _, dep := range a.UseDeps {
+		want, applies := dep.Requirement(parentUse)
+		if !applies {
+			continue
+		}
+		state, known := dep.ChildState(p)
+		if !known || state != want {
+			return false
+		}
+	}
+	return true
+}
+
+// Constraint преобразует атом в ограничение для решателя
+func (a *Atom) Constraint() Constraint {
+	return Constraint{
+		Type:    ConstraintTypeVersion,
+		Name:    a.Name(),
+		Version: a.Version,
+		Slot:    a.Slot,
+		Atom:    a,
+	}
+}
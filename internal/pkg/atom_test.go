@@ -0,0 +1,207 @@
+package pkg
+
+import "testing"
+
+func TestParseAtom(t *testing.T) {
+	tests := []struct {
+		atom     string
+		name     string
+		op       VersionOperator
+		version  string // Пустая строка - атом без версии
+		slot     string
+		subslot  string
+		slotOp   SlotOperator
+		blocker  BlockerType
+		repo     string
+		wantDeps []UseDep
+	}{
+		{atom: "dev-libs/openssl", name: "dev-libs/openssl"},
+		{atom: ">=dev-libs/openssl-3.0.9", name: "dev-libs/openssl", op: OpGreaterEqual, version: "3.0.9"},
+		{atom: "<=sys-libs/zlib-1.3-r1", name: "sys-libs/zlib", op: OpLessEqual, version: "1.3-r1"},
+		{atom: ">app-misc/foo-bar-1.0_rc2", name: "app-misc/foo-bar", op: OpGreater, version: "1.0_rc2"},
+		{atom: "<app-misc/foo-2", name: "app-misc/foo", op: OpLess, version: "2"},
+		{atom: "=app-misc/foo-1.2*", name: "app-misc/foo", op: OpEqualGlob, version: "1.2"},
+		{atom: "~app-misc/foo-1.2", name: "app-misc/foo", op: OpApproximate, version: "1.2"},
+		{atom: "=app-misc/foo-2bar-1.0", name: "app-misc/foo-2bar", op: OpEqual, version: "1.0"},
+		{atom: "dev-lang/python:3.11", name: "dev-lang/python", slot: "3.11"},
+		{atom: "dev-libs/openssl:0/3=", name: "dev-libs/openssl", slot: "0", subslot: "3", slotOp: SlotOpEqual},
+		{atom: "dev-libs/openssl:=", name: "dev-libs/openssl", slotOp: SlotOpEqual},
+		{atom: "dev-libs/openssl:*", name: "dev-libs/openssl", slotOp: SlotOpAny},
+		{atom: "!app-misc/foo", name: "app-misc/foo", blocker: BlockerWeak},
+		{atom: "!!<app-misc/foo-2", name: "app-misc/foo", op: OpLess, version: "2", blocker: BlockerStrong},
+		{atom: "app-misc/foo::gentoo", name: "app-misc/foo", repo: "gentoo"},
+		{
+			atom: "dev-libs/openssl:0::gentoo[static-libs,-test(-),zlib?,!bindist?,ssl=,!gui(+)=]",
+			name: "dev-libs/openssl", slot: "0", repo: "gentoo",
+			wantDeps: []UseDep{
+				{Flag: "static-libs", Type: UseDepEnabled},
+				{Flag: "test", Type: UseDepDisabled, Default: UseDefaultDisabled},
+				{Flag: "zlib", Type: UseDepIfEnabled},
+				{Flag: "bindist", Type: UseDepIfDisabled},
+				{Flag: "ssl", Type: UseDepEqual},
+				{Flag: "gui", Type: UseDepNotEqual, Default: UseDefaultEnabled},
+			},
+		},
+	}
+	for _, tt := range tests {
+		a, err := ParseAtom(tt.atom)
+		if err != nil {
+			t.Errorf("ParseAtom(%q) error: %v", tt.atom, err)
+			continue
+		}
+		if a.Name() != tt.name {
+			t.Errorf("ParseAtom(%q).Name() = %q, want %q", tt.atom, a.Name(), tt.name)
+		}
+		switch {
+		case tt.version == "" && a.Version != nil:
+			t.Errorf("ParseAtom(%q).Version = %v, want none", tt.atom, a.Version)
+		case tt.version != "" && (a.Version == nil || a.Version.Operator != tt.op || a.Version.Version != tt.version):
+			t.Errorf("ParseAtom(%q).Version = %v, want %v %s", tt.atom, a.Version, tt.op, tt.version)
+		}
+		if a.Slot != tt.slot || a.Subslot != tt.subslot || a.SlotOp != tt.slotOp {
+			t.Errorf("ParseAtom(%q) slot = %q/%q op %v", tt.atom, a.Slot, a.Subslot, a.SlotOp)
+		}
+		if a.Blocker != tt.blocker || a.Repository != tt.repo {
+			t.Errorf("ParseAtom(%q) blocker = %v, repository = %q", tt.atom, a.Blocker, a.Repository)
+		}
+		if len(a.UseDeps) != len(tt.wantDeps) {
+			t.Errorf("ParseAtom(%q).UseDeps = %v, want %v", tt.atom, a.UseDeps, tt.wantDeps)
+			continue
+		}
+		for i, d := range tt.wantDeps {
+			if a.UseDeps[i] != d {
+				t.Errorf("ParseAtom(%q).UseDeps[%d] = %+v, want %+v", tt.atom, i, a.UseDeps[i], d)
+			}
+		}
+
+		// String() дает атом, который разбирается в то же самое
+		if s := a.String(); s != tt.atom {
+			t.Errorf("ParseAtom(%q).String() = %q", tt.atom, s)
+		}
+	}
+}
+
+func TestParseAtomErrors(t *testing.T) {
+	tests := []string{
+		"openssl",
+		"dev-libs/openssl-3.0",
+		"=dev-libs/openssl",
+		">=dev-libs/openssl-3*",
+		"dev-libs/openssl*",
+		"~dev-libs/openssl-3.0-r1",
+		"dev-libs/openssl[ssl",
+		"dev-libs/openssl]",
+		"dev-libs/openssl[]",
+		"dev-libs/openssl[+ssl]",
+		"dev-libs/openssl:",
+		"dev-libs/openssl:0/",
+		"dev-libs/openssl::",
+		"-dev/openssl",
+		"=app-misc/foo-1a-1.0",
+	}
+	for _, s := range tests {
+		if a, err := ParseAtom(s); err == nil {
+			t.Errorf("ParseAtom(%q) = %v, want error", s, a)
+		}
+	}
+}
+
+func TestAtomMatch(t *testing.T) {
+	p := NewPackage("dev-libs/openssl", "3.0.9-r1", "0/3")
+	p.Repository = "gentoo"
+	p.UseFlags = map[string]bool{"ssl": true, "test": false}
+
+	tests := []struct {
+		atom  string
+		match bool
+	}{
+		{"dev-libs/openssl", true},
+		{">=dev-libs/openssl-3.0.9", true},
+		{">dev-libs/openssl-3.0.9-r1", false},
+		{"~dev-libs/openssl-3.0.9", true},
+		{"=dev-libs/openssl-3*", true},
+		{"=dev-libs/openssl-3.1*", false},
+		{"<dev-libs/openssl-3.0.10", true},
+		{"dev-libs/openssl:0/3", true},
+		{"dev-libs/openssl:0/1.1", false},
+		{"dev-libs/openssl:1", false},
+		{"dev-libs/openssl::gentoo", true},
+		{"dev-libs/openssl::overlay", false},
+		{"dev-libs/libressl", false},
+	}
+	for _, tt := range tests {
+		a, err := ParseAtom(tt.atom)
+		if err != nil {
+			t.Fatalf("ParseAtom(%q) error: %v", tt.atom, err)
+		}
+		if got := a.Match(p); got != tt.match {
+			t.Errorf("%s.Match(%s-%s) = %v, want %v", tt.atom, p.Name, p.Version, got, tt.match)
+		}
+	}
+}
+
+func TestAtomMatchUse(t *testing.T) {
+	p := NewPackage("dev-libs/openssl", "3.0.9", "0")
+	p.UseFlags = map[string]bool{"ssl": true, "test": false}
+
+	tests := []struct {
+		deps   string
+		parent map[string]bool
+		match  bool
+	}{
+		{"[ssl]", nil, true},
+		{"[-ssl]", nil, false},
+		{"[test]", nil, false},
+		{"[missing]", nil, false},
+		{"[missing(+)]", nil, true},
+		{"[-missing(-)]", nil, true},
+		{"[test?]", map[string]bool{"test": false}, true},
+		{"[test?]", map[string]bool{"test": true}, false},
+		{"[!ssl?]", map[string]bool{"ssl": true}, true},
+		{"[ssl=]", map[string]bool{"ssl": true}, true},
+		{"[ssl=]", map[string]bool{}, false},
+		{"[!test=]", map[string]bool{}, false},
+		{"[!test=]", map[string]bool{"test": true}, true},
+	}
+	for _, tt := range tests {
+		a, err := ParseAtom("dev-libs/openssl" + tt.deps)
+		if err != nil {
+			t.Fatalf("ParseAtom(%q) error: %v", tt.deps, err)
+		}
+		if got := a.MatchUse(p, tt.parent); got != tt.match {
+			t.Errorf("MatchUse(%s, parent USE %v) = %v, want %v", tt.deps, tt.parent, got, tt.match)
+		}
+	}
+}
+
+func TestAtomEqual(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{"app-misc/foo", "app-misc/foo", true},
+		{"=app-misc/foo-1.0", "=app-misc/foo-1.0-r0", true},
+		{">=app-misc/foo-1.0", ">=app-misc/foo-1.0.1", false},
+		{">=app-misc/foo-1.0", ">app-misc/foo-1.0", false},
+		{"=app-misc/foo-1*", "=app-misc/foo-1", false},
+		{"app-misc/foo[a,-b]", "app-misc/foo[-b,a]", true},
+		{"app-misc/foo[a]", "app-misc/foo[a,b]", false},
+		{"app-misc/foo:0", "app-misc/foo", false},
+		{"app-misc/foo::gentoo", "app-misc/foo", false},
+		{"!app-misc/foo", "app-misc/foo", false},
+		{"app-misc/foo", "app-misc/bar", false},
+	}
+	for _, tt := range tests {
+		a, err := ParseAtom(tt.a)
+		if err != nil {
+			t.Fatalf("ParseAtom(%q) error: %v", tt.a, err)
+		}
+		b, err := ParseAtom(tt.b)
+		if err != nil {
+			t.Fatalf("ParseAtom(%q) error: %v", tt.b, err)
+		}
+		if got := a.Equal(b); got != tt.equal {
+			t.Errorf("%s.Equal(%s) = %v, want %v", tt.a, tt.b, got, tt.equal)
+		}
+	}
+}
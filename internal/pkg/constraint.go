@@ -1,8 +1,7 @@
 package pkg
 
 import (
-	"regexp"
-	"strconv"
+	"fmt"
 	"strings"
 )
 
@@ -33,6 +32,8 @@ const (
 	OpGreaterEqual
 	OpLess
 	OpLessEqual
+	OpApproximate // ~: та же версия без учета ревизии
+	OpEqualGlob   // =ver*: совпадение по префиксу компонентов версии
 )
 
 // VersionConstraint представляет ограничение версии
@@ -50,9 +51,13 @@ type Constraint struct {
 	Flag      string             // Для USE-флагов
 	Required  bool               // Обязательное требование
 	Condition string             // Условие USE-флага
+	Atom      *Atom              // Исходный атом, если ограничение получено из зависимости
 }
 
 func (c Constraint) String() string {
+	if c.Atom != nil {
+		return c.Atom.String()
+	}
 	if c.Version == nil {
 		return c.Name
 	}
@@ -99,6 +104,10 @@ func (vc *VersionConstraint) String() string {
 		return "<" + vc.Version
 	case OpLessEqual:
 		return "<=" + vc.Version
+	case OpApproximate:
+		return "~" + vc.Version
+	case OpEqualGlob:
+		return "=" + vc.Version + "*"
 	default:
 		return "unknown"
 	}
@@ -112,7 +121,7 @@ func (vc *VersionConstraint) Satisfies(version string) bool {
 
 	switch vc.Operator {
 	case OpEqual:
-		return version == vc.Version
+		return CompareVersions(version, vc.Version) == 0
 	case OpGreater:
 		return CompareVersions(version, vc.Version) > 0
 	case OpGreaterEqual:
@@ -121,59 +130,36 @@ func (vc *VersionConstraint) Satisfies(version string) bool {
 		return CompareVersions(version, vc.Version) < 0
 	case OpLessEqual:
 		return CompareVersions(version, vc.Version) <= 0
+	case OpApproximate:
+		return matchVersions(version, vc.Version, func(v, want *Version) bool {
+			return v.WithoutRevision().Compare(want.WithoutRevision()) == 0
+		})
+	case OpEqualGlob:
+		return matchVersions(version, vc.Version, (*Version).HasPrefix)
 	default:
 		return true
 	}
 }
 
-// CompareVersions сравнивает версии в формате Gentoo
+// CompareVersions сравнивает версии в формате Gentoo по алгоритму PMS.
+// Некорректные версии сравниваются как строки.
 func CompareVersions(v1, v2 string) int {
-	// Разбиваем версии на компоненты: 1.2.3_alpha4-r5 -> [1, 2, 3, "alpha", 4, 5]
-	splitVersion := func(v string) []interface{} {
-		// Разделяем цифры и буквы
-		re := regexp.MustCompile(`(\d+)|([a-zA-Z]+)`)
-		parts := re.FindAllString(v, -1)
-
-		var result []interface{}
-		for _, part := range parts {
-			if num, err := strconv.Atoi(part); err == nil {
-				result = append(result, num)
-			} else {
-				result = append(result, part)
-			}
-		}
-		return result
+	a, err1 := ParseVersion(v1)
+	b, err2 := ParseVersion(v2)
+	if err1 != nil || err2 != nil {
+		return strings.Compare(v1, v2)
 	}
+	return a.Compare(b)
+}
 
-	parts1 := splitVersion(v1)
-	parts2 := splitVersion(v2)
-
-	// Сравниваем компоненты
-	for i := 0; i < len(parts1) && i < len(parts2); i++ {
-		switch a := parts1[i].(type) {
-		case int:
-			if b, ok := parts2[i].(int); ok {
-				if a != b {
-					return a - b
-				}
-			} else {
-				// Число всегда больше строки
-				return 1
-			}
-		case string:
-			if b, ok := parts2[i].(string); ok {
-				if cmp := strings.Compare(a, b); cmp != 0 {
-					return cmp
-				}
-			} else {
-				// Строка всегда меньше числа
-				return -1
-			}
-		}
+// matchVersions разбирает обе версии и применяет к ним функцию сравнения
+func matchVersions(version, want string, match func(v, want *Version) bool) bool {
+	v, err1 := ParseVersion(version)
+	w, err2 := ParseVersion(want)
+	if err1 != nil || err2 != nil {
+		return false
 	}
-
-	// Если все компоненты равны, более длинная версия считается большей
-	return len(parts1) - len(parts2)
+	return match(v, w)
 }
 
 // ParseVersionConstraint парсит строковое представление ограничения
@@ -182,24 +168,40 @@ func ParseVersionConstraint(s string) (*VersionConstraint, error) {
 		return nil, nil
 	}
 
-	operators := map[string]VersionOperator{
-		"=":  OpEqual,
-		">":  OpGreater,
-		">=": OpGreaterEqual,
-		"<":  OpLess,
-		"<=": OpLessEqual,
+	// Двухсимвольные операторы проверяются раньше односимвольных
+	operators := []struct {
+		str string
+		op  VersionOperator
+	}{
+		{">=", OpGreaterEqual},
+		{"<=", OpLessEqual},
+		{"=", OpEqual},
+		{">", OpGreater},
+		{"<", OpLess},
+		{"~", OpApproximate},
 	}
 
-	for opStr, op := range operators {
-		if strings.HasPrefix(s, opStr) {
-			version := strings.TrimSpace(strings.TrimPrefix(s, opStr))
+	for _, o := range operators {
+		if strings.HasPrefix(s, o.str) {
+			version := strings.TrimSpace(strings.TrimPrefix(s, o.str))
+			if o.op == OpEqual && strings.HasSuffix(version, "*") {
+				o.op = OpEqualGlob
+				version = strings.TrimSuffix(version, "*")
+			}
+			if !IsValidVersion(version) {
+				return nil, fmt.Errorf("invalid version in constraint %q", s)
+			}
 			return &VersionConstraint{
-				Operator: op,
+				Operator: o.op,
 				Version:  version,
 			}, nil
 		}
 	}
 
+	if !IsValidVersion(s) {
+		return nil, fmt.Errorf("invalid version in constraint %q", s)
+	}
+
 	// По умолчанию считается точной версией
 	return &VersionConstraint{
 		Operator: OpEqual,
@@ -0,0 +1,220 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DepSpecKind определяет тип узла спецификации зависимостей
+type DepSpecKind int
+
+const (
+	DepAllOf          DepSpecKind = iota // ( a b ) и корень спецификации
+	DepAnyOf                             // || ( a b )
+	DepUseConditional                    // flag? ( a ) или !flag? ( a )
+	DepAtom                              // Атом зависимости
+	DepExactlyOneOf                      // ^^ ( a b ) в REQUIRED_USE
+	DepAtMostOneOf                       // ?? ( a b ) в REQUIRED_USE
+	DepUseFlag                           // Флаг flag или !flag в REQUIRED_USE
+	DepLicense                           // Имя лицензии в LICENSE
+)
+
+// DepSpec представляет узел дерева спецификации зависимостей (PMS, раздел 8.2)
+type DepSpec struct {
+	Kind     DepSpecKind
+	Atom     *Atom      // Для DepAtom
+	Flag     string     // Для DepUseConditional и DepUseFlag
+	Negate   bool       // !flag? ( ... ) или !flag
+	License  string     // Для DepLicense
+	Children []*DepSpec // Для групп
+}
+
+// ParseDepSpec разбирает строку *DEPEND в дерево зависимостей.
+// Атомы проверяются на соответствие EAPI; nil снимает проверку.
+func ParseDepSpec(s string, eapi *EAPI) (*DepSpec, error) {
+	parseAtom := ParseAtom
+	if eapi != nil {
+		parseAtom = func(s string) (*Atom, error) { return ParseAtomEAPI(s, eapi) }
+	}
+	return parseSpecTree(s, func(token string) (*DepSpec, error) {
+		atom, err := parseAtom(token)
+		if err != nil {
+			return nil, err
+		}
+		return &DepSpec{Kind: DepAtom, Atom: atom}, nil
+	}, false)
+}
+
+// parseSpecTree разбирает общий синтаксис групп; листья создаются функцией leaf.
+// oneOf разрешает группы ^^ ( ) и ?? ( ), допустимые только в REQUIRED_USE.
+func parseSpecTree(s string, leaf func(token string) (*DepSpec, error), oneOf bool) (*DepSpec, error) {
+	tokens := strings.Fields(s)
+	root := &DepSpec{Kind: DepAllOf}
+	pos, err := parseSpecGroup(tokens, 0, root, leaf, oneOf)
+	if err != nil {
+		return nil, err
+	}
+	if pos != len(tokens) {
+		return nil, fmt.Errorf("unexpected %q at token %d", tokens[pos], pos)
+	}
+	return root, nil
+}
+
+// parseSpecGroup заполняет group дочерними узлами до закрывающей скобки или конца строки.
+// Возвращает позицию закрывающей скобки (или len(tokens)).
+func parseSpecGroup(tokens []string, pos int, group *DepSpec, leaf func(string) (*DepSpec, error), oneOf bool) (int, error) {
+	for pos < len(tokens) {
+		token := tokens[pos]
+
+		var node *DepSpec
+		switch {
+		case token == ")":
+			return pos, nil
+		case token == "(":
+			node = &DepSpec{Kind: DepAllOf}
+		case token == "||":
+			node = &DepSpec{Kind: DepAnyOf}
+		case oneOf && token == "^^":
+			node = &DepSpec{Kind: DepExactlyOneOf}
+		case oneOf && token == "??":
+			node = &DepSpec{Kind: DepAtMostOneOf}
+		case strings.HasSuffix(token, "?"):
+			flag := strings.TrimSuffix(token, "?")
+			node = &DepSpec{Kind: DepUseConditional}
+			if strings.HasPrefix(flag, "!") {
+				node.Negate = true
+				flag = flag[1:]
+			}
+			if !useFlagRe.MatchString(flag) {
+				return pos, fmt.Errorf("invalid USE conditional %q", token)
+			}
+			node.Flag = flag
+		default:
+			l, err := leaf(token)
+			if err != nil {
+				return pos, err
+			}
+			group.Children = append(group.Children, l)
+			pos++
+			continue
+		}
+
+		// Операторы групп должны сопровождаться открывающей скобкой
+		if token != "(" {
+			pos++
+			if pos >= len(tokens) || tokens[pos] != "(" {
+				return pos, fmt.Errorf("expected '(' after %q", token)
+			}
+		}
+
+		end, err := parseSpecGroup(tokens, pos+1, node, leaf, oneOf)
+		if err != nil {
+			return end, err
+		}
+		if end >= len(tokens) {
+			return end, fmt.Errorf("unbalanced parentheses")
+		}
+		group.Children = append(group.Children, node)
+		pos = end + 1
+	}
+	return pos, nil
+}
+
+// String возвращает строковое представление спецификации
+func (d *DepSpec) String() string {
+	if d == nil {
+		return ""
+	}
+	return strings.Join(d.childStrings(), " ")
+}
+
+func (d *DepSpec) childStrings() []string {
+	parts := make([]string, 0, len(d.Children))
+	for _, c := range d.Children {
+		parts = append(parts, c.nodeString())
+	}
+	return parts
+}
+
+func (d *DepSpec) nodeString() string {
+	group := "( " + strings.Join(d.childStrings(), " ") + " )"
+	switch d.Kind {
+	case DepAtom:
+		return d.Atom.String()
+	case DepAnyOf:
+		return "|| " + group
+	case DepExactlyOneOf:
+		return "^^ " + group
+	case DepAtMostOneOf:
+		return "?? " + group
+	case DepLicense:
+		return d.License
+	case DepUseFlag:
+		if d.Negate {
+			return "!" + d.Flag
+		}
+		return d.Flag
+	case DepUseConditional:
+		prefix := ""
+		if d.Negate {
+			prefix = "!"
+		}
+		return prefix + d.Flag + "? " + group
+	default:
+		return group
+	}
+}
+
+// ConditionMet проверяет выполнение USE-условия узла для набора флагов
+func (d *DepSpec) ConditionMet(use map[string]bool) bool {
+	return use[d.Flag] != d.Negate
+}
+
+// Evaluate возвращает копию дерева, в которой USE-условия раскрыты
+// в соответствии с набором флагов пакета
+func (d *DepSpec) Evaluate(use map[string]bool) *DepSpec {
+	if d == nil {
+		return nil
+	}
+	if d.Kind == DepAtom {
+		return d
+	}
+
+	result := &DepSpec{Kind: d.Kind}
+	if d.Kind == DepUseConditional {
+		// Выполненное условие превращается в обычную группу
+		result.Kind = DepAllOf
+	}
+	for _, c := range d.Children {
+		if c.Kind == DepUseConditional && !c.ConditionMet(use) {
+			continue
+		}
+		result.Children = append(result.Children, c.Evaluate(use))
+	}
+	return result
+}
+
+// Atoms возвращает все атомы дерева, включая альтернативы и условные ветви
+func (d *DepSpec) Atoms() []*Atom {
+	if d == nil {
+		return nil
+	}
+	if d.Kind == DepAtom {
+		return []*Atom{d.Atom}
+	}
+	var atoms []*Atom
+	for _, c := range d.Children {
+		atoms = append(atoms, c.Atoms()...)
+	}
+	return atoms
+}
+
+// Constraints возвращает атомы дерева в виде плоского списка ограничений
+func (d *DepSpec) Constraints() []Constraint {
+	atoms := d.Atoms()
+	constraints := make([]Constraint, 0, len(atoms))
+	for _, a := range atoms {
+		constraints = append(constraints, a.Constraint())
+	}
+	return constraints
+}
@@ -0,0 +1,98 @@
+package pkg
+
+import "testing"
+
+func TestParseDepSpec(t *testing.T) {
+	tests := []struct {
+		spec  string
+		atoms int
+	}{
+		{"", 0},
+		{"dev-libs/openssl", 1},
+		{">=dev-libs/openssl-3 sys-libs/zlib:=", 2},
+		{"|| ( dev-libs/openssl dev-libs/libressl )", 2},
+		{"ssl? ( dev-libs/openssl ) !ssl? ( net-libs/gnutls )", 2},
+		{"( app-misc/a app-misc/b ) || ( ( app-misc/c app-misc/d ) app-misc/e )", 5},
+		{"test? ( || ( dev-lang/python:3.11 dev-lang/python:3.12 ) )", 2},
+	}
+	for _, tt := range tests {
+		spec, err := ParseDepSpec(tt.spec, nil)
+		if err != nil {
+			t.Errorf("ParseDepSpec(%q) error: %v", tt.spec, err)
+			continue
+		}
+		if got := len(spec.Atoms()); got != tt.atoms {
+			t.Errorf("ParseDepSpec(%q) has %d atoms, want %d", tt.spec, got, tt.atoms)
+		}
+		if s := spec.String(); s != tt.spec {
+			t.Errorf("ParseDepSpec(%q).String() = %q", tt.spec, s)
+		}
+	}
+}
+
+func TestParseDepSpecErrors(t *testing.T) {
+	tests := []string{
+		"( dev-libs/openssl",
+		"dev-libs/openssl )",
+		"|| dev-libs/openssl",
+		"ssl?",
+		"ssl? dev-libs/openssl",
+		"^^ ( dev-libs/a dev-libs/b )",
+		"?? ( dev-libs/a dev-libs/b )",
+		"bad?flag? ( dev-libs/a )",
+		"openssl",
+	}
+	for _, s := range tests {
+		if spec, err := ParseDepSpec(s, nil); err == nil {
+			t.Errorf("ParseDepSpec(%q) = %q, want error", s, spec)
+		}
+	}
+}
+
+func TestParseDepSpecEAPI(t *testing.T) {
+	tests := []struct {
+		eapi, spec string
+		valid      bool
+	}{
+		{"0", "dev-libs/openssl:0", false},
+		{"1", "dev-libs/openssl:0", true},
+		{"0", "dev-libs/openssl[ssl]", false},
+		{"2", "dev-libs/openssl[ssl]", true},
+		{"2", "dev-libs/openssl[ssl(+)]", false},
+		{"4", "dev-libs/openssl[ssl(+)]", true},
+		{"4", "dev-libs/openssl:=", false},
+		{"5", "dev-libs/openssl:0/3=", true},
+	}
+	for _, tt := range tests {
+		eapi, err := LookupEAPI(tt.eapi)
+		if err != nil {
+			t.Fatalf("LookupEAPI(%q) error: %v", tt.eapi, err)
+		}
+		if _, err := ParseDepSpec(tt.spec, eapi); (err == nil) != tt.valid {
+			t.Errorf("ParseDepSpec(%q, EAPI %s) error = %v, want valid = %v", tt.spec, tt.eapi, err, tt.valid)
+		}
+	}
+}
+
+func TestDepSpecEvaluate(t *testing.T) {
+	spec, err := ParseDepSpec("app-misc/a ssl? ( dev-libs/openssl ) !ssl? ( net-libs/gnutls ) test? ( || ( dev-util/b test? ( dev-util/c ) ) )", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		use  map[string]bool
+		want string
+	}{
+		{nil, "app-misc/a ( net-libs/gnutls )"},
+		{map[string]bool{"ssl": true}, "app-misc/a ( dev-libs/openssl )"},
+		{map[string]bool{"ssl": true, "test": true}, "app-misc/a ( dev-libs/openssl ) ( || ( dev-util/b ( dev-util/c ) ) )"},
+	}
+	for _, tt := range tests {
+		if got := spec.Evaluate(tt.use).String(); got != tt.want {
+			t.Errorf("Evaluate(%v) = %q, want %q", tt.use, got, tt.want)
+		}
+	}
+	if len(spec.Atoms()) != 5 {
+		t.Errorf("Evaluate changed the original tree: %s", spec)
+	}
+}
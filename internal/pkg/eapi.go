@@ -0,0 +1,101 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// ErrUnsupportedEAPI возвращается для ebuild с неизвестным EAPI
+var ErrUnsupportedEAPI = errors.New("unsupported EAPI")
+
+// EAPI описывает возможности версии EAPI, влияющие на разбор и разрешение зависимостей
+type EAPI struct {
+	Name           string
+	IUseDefaults   bool // +flag и -flag в IUSE (1+)
+	SlotDeps       bool // :slot в атомах (1+)
+	UseDeps        bool // [flag] в атомах (2+)
+	StrongBlockers bool // !!atom (2+)
+	RequiredUse    bool // REQUIRED_USE и ^^ ( ) (4+)
+	EmptyGroupTrue bool // Пустые группы || ( ) и ^^ ( ) в REQUIRED_USE выполнены (0-7)
+	UseDepDefaults bool // flag(+) и flag(-) в USE-зависимостях (4+)
+	SlotOperators  bool // Подслоты и операторы := и :* (5+)
+	AtMostOneOf    bool // ?? ( ) в REQUIRED_USE (5+)
+	BDepend        bool // BDEPEND (7+)
+	IDepend        bool // IDEPEND (8+)
+}
+
+// LatestEAPI - наибольший поддерживаемый EAPI
+const LatestEAPI = "8"
+
+// eapis - таблица поддерживаемых EAPI (PMS, таблицы возможностей EAPI)
+var eapis = func() map[string]*EAPI {
+	table := make(map[string]*EAPI)
+	latest, _ := strconv.Atoi(LatestEAPI)
+	for n := 0; n <= latest; n++ {
+		table[strconv.Itoa(n)] = &EAPI{
+			Name:           strconv.Itoa(n),
+			IUseDefaults:   n >= 1,
+			SlotDeps:       n >= 1,
+			UseDeps:        n >= 2,
+			StrongBlockers: n >= 2,
+			RequiredUse:    n >= 4,
+			EmptyGroupTrue: n < 8,
+			UseDepDefaults: n >= 4,
+			SlotOperators:  n >= 5,
+			AtMostOneOf:    n >= 5,
+			BDepend:        n >= 7,
+			IDepend:        n >= 8,
+		}
+	}
+	return table
+}()
+
+// LookupEAPI возвращает возможности EAPI; пустое значение означает EAPI 0
+func LookupEAPI(name string) (*EAPI, error) {
+	if name == "" {
+		name = "0"
+	}
+	e, ok := eapis[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q (supported: 0-%s)", ErrUnsupportedEAPI, name, LatestEAPI)
+	}
+	return e, nil
+}
+
+// CheckAtom проверяет, что атом использует только возможности EAPI.
+// Ограничение репозитория (::repo) в ebuild не допускается ни в одном EAPI.
+func (e *EAPI) CheckAtom(a *Atom) error {
+	switch {
+	case a.Repository != "":
+		return fmt.Errorf("repository dependencies are not allowed in EAPI %s", e.Name)
+	case a.Blocker == BlockerStrong && !e.StrongBlockers:
+		return fmt.Errorf("strong blockers are not allowed in EAPI %s", e.Name)
+	case a.Slot != "" && !e.SlotDeps:
+		return fmt.Errorf("slot dependencies are not allowed in EAPI %s", e.Name)
+	case (a.Subslot != "" || a.SlotOp != SlotOpNone) && !e.SlotOperators:
+		return fmt.Errorf("slot operators and subslots are not allowed in EAPI %s", e.Name)
+	case len(a.UseDeps) > 0 && !e.UseDeps:
+		return fmt.Errorf("USE dependencies are not allowed in EAPI %s", e.Name)
+	}
+	if !e.UseDepDefaults {
+		for _, dep := range a.UseDeps {
+			if dep.Default != UseDefaultNone {
+				return fmt.Errorf("USE dependency defaults are not allowed in EAPI %s", e.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// ParseAtomEAPI разбирает атом из метаданных ebuild с проверкой возможностей EAPI
+func ParseAtomEAPI(s string, e *EAPI) (*Atom, error) {
+	a, err := ParseAtom(s)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.CheckAtom(a); err != nil {
+		return nil, fmt.Errorf("invalid atom %q: %w", s, err)
+	}
+	return a, nil
+}
@@ -0,0 +1,73 @@
+package pkg
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLookupEAPI(t *testing.T) {
+	tests := []struct {
+		name string
+		want EAPI
+	}{
+		{"", EAPI{Name: "0", EmptyGroupTrue: true}},
+		{"0", EAPI{Name: "0", EmptyGroupTrue: true}},
+		{"1", EAPI{Name: "1", IUseDefaults: true, SlotDeps: true, EmptyGroupTrue: true}},
+		{"2", EAPI{Name: "2", IUseDefaults: true, SlotDeps: true, UseDeps: true, StrongBlockers: true, EmptyGroupTrue: true}},
+		{"4", EAPI{Name: "4", IUseDefaults: true, SlotDeps: true, UseDeps: true, StrongBlockers: true,
+			RequiredUse: true, EmptyGroupTrue: true, UseDepDefaults: true}},
+		{"5", EAPI{Name: "5", IUseDefaults: true, SlotDeps: true, UseDeps: true, StrongBlockers: true,
+			RequiredUse: true, EmptyGroupTrue: true, UseDepDefaults: true, SlotOperators: true, AtMostOneOf: true}},
+		{"7", EAPI{Name: "7", IUseDefaults: true, SlotDeps: true, UseDeps: true, StrongBlockers: true,
+			RequiredUse: true, EmptyGroupTrue: true, UseDepDefaults: true, SlotOperators: true, AtMostOneOf: true, BDepend: true}},
+		{"8", EAPI{Name: "8", IUseDefaults: true, SlotDeps: true, UseDeps: true, StrongBlockers: true,
+			RequiredUse: true, UseDepDefaults: true, SlotOperators: true, AtMostOneOf: true, BDepend: true, IDepend: true}},
+	}
+	for _, tt := range tests {
+		got, err := LookupEAPI(tt.name)
+		if err != nil {
+			t.Errorf("LookupEAPI(%q) error: %v", tt.name, err)
+			continue
+		}
+		if *got != tt.want {
+			t.Errorf("LookupEAPI(%q) = %+v, want %+v", tt.name, *got, tt.want)
+		}
+	}
+
+	for _, name := range []string{"9", "4-python", "-1", "01"} {
+		if _, err := LookupEAPI(name); !errors.Is(err, ErrUnsupportedEAPI) {
+			t.Errorf("LookupEAPI(%q) error = %v, want ErrUnsupportedEAPI", name, err)
+		}
+	}
+}
+
+func TestCheckAtom(t *testing.T) {
+	tests := []struct {
+		atom  string
+		eapi  string
+		valid bool
+	}{
+		{"dev-libs/openssl", "0", true},
+		{"dev-lang/python:3.11", "0", false},
+		{"dev-lang/python:3.11", "1", true},
+		{"dev-libs/openssl[ssl]", "1", false},
+		{"dev-libs/openssl[ssl]", "2", true},
+		{"!!app-misc/foo", "1", false},
+		{"!!app-misc/foo", "2", true},
+		{"dev-libs/openssl[ssl(+)]", "3", false},
+		{"dev-libs/openssl[ssl(+)]", "4", true},
+		{"dev-libs/openssl:=", "4", false},
+		{"dev-libs/openssl:0/3", "4", false},
+		{"dev-libs/openssl:=", "5", true},
+		{"dev-libs/openssl::gentoo", "8", false},
+	}
+	for _, tt := range tests {
+		eapi, err := LookupEAPI(tt.eapi)
+		if err != nil {
+			t.Fatalf("LookupEAPI(%q) error: %v", tt.eapi, err)
+		}
+		if _, err := ParseAtomEAPI(tt.atom, eapi); (err == nil) != tt.valid {
+			t.Errorf("ParseAtomEAPI(%q, EAPI %s) error = %v, want valid = %v", tt.atom, tt.eapi, err, tt.valid)
+		}
+	}
+}
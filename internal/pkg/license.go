@@ -0,0 +1,64 @@
+package pkg
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// licenseNameRe - допустимое имя лицензии (PMS, раздел 3.1.6)
+var licenseNameRe = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9+_.-]*$`)
+
+// ParseLicense разбирает строку LICENSE с группами || ( ) и USE-условиями
+func ParseLicense(s string) (*DepSpec, error) {
+	return parseSpecTree(s, func(token string) (*DepSpec, error) {
+		if !licenseNameRe.MatchString(token) {
+			return nil, fmt.Errorf("invalid license name %q", token)
+		}
+		return &DepSpec{Kind: DepLicense, License: token}, nil
+	}, false)
+}
+
+// Licenses возвращает лицензии, которые нужно принять для установки пакета с набором флагов use.
+// В группе || ( ) достаточно первой альтернативы, все лицензии которой приняты;
+// если таких нет, возвращается первая альтернатива. Второй результат сообщает,
+// выполнено ли требование при функции accepted.
+func (d *DepSpec) Licenses(use map[string]bool, accepted func(license string) bool) ([]string, bool) {
+	if d == nil {
+		return nil, true
+	}
+
+	switch d.Kind {
+	case DepLicense:
+		return []string{d.License}, accepted(d.License)
+	case DepUseConditional:
+		if !d.ConditionMet(use) {
+			return nil, true
+		}
+	case DepAnyOf:
+		var first []string
+		applicable := false
+		for _, c := range d.Children {
+			if c.Kind == DepUseConditional && !c.ConditionMet(use) {
+				continue
+			}
+			licenses, ok := c.Licenses(use, accepted)
+			if ok {
+				return licenses, true
+			}
+			if !applicable {
+				first = licenses
+				applicable = true
+			}
+		}
+		return first, !applicable
+	}
+
+	var all []string
+	satisfied := true
+	for _, c := range d.Children {
+		licenses, ok := c.Licenses(use, accepted)
+		all = append(all, licenses...)
+		satisfied = satisfied && ok
+	}
+	return all, satisfied
+}
@@ -1,6 +1,7 @@
 package pkg
 
 import (
+	"sort"
 	"strings"
 )
 
@@ -33,12 +34,24 @@ func ParseSlot(slot string) Slot {
 }
 
 type Package struct {
-	Name     string
-	Version  string
-	Slot     Slot
-	UseFlags map[string]bool
-	Deps     []Constraint
-	Provides []Constraint // Виртуальные пакеты
+	Name        string
+	Version     string
+	Slot        Slot
+	IUse        []string        // IUSE с префиксами значений по умолчанию (+flag, -flag)
+	UseFlags    map[string]bool // Состояние флагов IUSE (по умолчанию - значения из IUSE)
+	Deps        []Constraint
+	Depend      *DepSpec          // DEPEND: зависимости сборки для целевой системы
+	BDepend     *DepSpec          // BDEPEND: зависимости сборки, выполняемые на сборочной системе
+	RDepend     *DepSpec          // RDEPEND: зависимости времени выполнения
+	PDepend     *DepSpec          // PDEPEND: зависимости времени выполнения, устанавливаемые после пакета
+	IDepend     *DepSpec          // IDEPEND: зависимости, нужные при установке пакета
+	RequiredUse *DepSpec          // Ограничения REQUIRED_USE
+	License     *DepSpec          // LICENSE
+	Provides    []Constraint      // Виртуальные пакеты
+	Eclasses    map[string]string // Унаследованные eclass -> md5
+	Keywords    []string          // KEYWORDS: amd64, ~arm64, -*
+	Repository  string            // Репозиторий, из которого получен пакет (для установленных - исходный)
+	EAPI        *EAPI             // nil - EAPI неизвестен (пакеты без метаданных ebuild)
 }
 
 // NewPackage создает новый экземпляр пакета
@@ -50,9 +63,31 @@ func NewPackage(name, version, slotStr string) *Package {
 		UseFlags: make(map[string]bool),
 		Deps:     make([]Constraint, 0),
 		Provides: make([]Constraint, 0),
+		Eclasses: make(map[string]string),
 	}
 }
 
+// Inherits проверяет, наследует ли пакет указанный eclass (напрямую или косвенно)
+func (p *Package) Inherits(eclass string) bool {
+	_, ok := p.Eclasses[eclass]
+	return ok
+}
+
+// HasDepSpecs проверяет, описаны ли зависимости пакета деревьями *DEPEND.
+// Иначе зависимости заданы только плоским списком Deps.
+func (p *Package) HasDepSpecs() bool {
+	return p.Depend != nil || p.BDepend != nil || p.RDepend != nil || p.PDepend != nil || p.IDepend != nil
+}
+
+// IUseNames возвращает имена флагов IUSE без префиксов значений по умолчанию
+func (p *Package) IUseNames() []string {
+	names := make([]string, 0, len(p.IUse))
+	for _, flag := range p.IUse {
+		names = append(names, strings.TrimLeft(flag, "+-"))
+	}
+	return names
+}
+
 // AddDependency добавляет зависимость к пакету
 func (p *Package) AddDependency(constraint Constraint) {
 	p.Deps = append(p.Deps, constraint)
@@ -68,3 +103,10 @@ func (p *Package) ConflictsWith(other *Package) bool {
 	// Конфликт, если слоты совпадают, но под-слоты разные
 	return p.Slot.Name == other.Slot.Name && p.Slot.Subslot != other.Slot.Subslot
 }
+
+// SortByVersion сортирует версии пакетов по убыванию
+func SortByVersion(packages []*Package) {
+	sort.SliceStable(packages, func(i, j int) bool {
+		return CompareVersions(packages[i].Version, packages[j].Version) > 0
+	})
+}
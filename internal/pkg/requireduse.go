@@ -0,0 +1,98 @@
+package pkg
+
+import "fmt"
+
+// ParseRequiredUse разбирает строку REQUIRED_USE (PMS, раздел 7.3.5).
+// Группы ?? ( ) проверяются на соответствие EAPI; nil снимает проверку.
+func ParseRequiredUse(s string, eapi *EAPI) (*DepSpec, error) {
+	if eapi != nil && !eapi.RequiredUse {
+		return nil, fmt.Errorf("REQUIRED_USE is not allowed in EAPI %s", eapi.Name)
+	}
+	spec, err := parseSpecTree(s, func(token string) (*DepSpec, error) {
+		node := &DepSpec{Kind: DepUseFlag, Flag: token}
+		if token[0] == '!' {
+			node.Negate = true
+			node.Flag = token[1:]
+		}
+		if !useFlagRe.MatchString(node.Flag) {
+			return nil, fmt.Errorf("invalid REQUIRED_USE flag %q", token)
+		}
+		return node, nil
+	}, true)
+	if err != nil {
+		return nil, err
+	}
+	if eapi != nil && !eapi.AtMostOneOf && spec.contains(DepAtMostOneOf) {
+		return nil, fmt.Errorf("?? groups are not allowed in EAPI %s", eapi.Name)
+	}
+	return spec, nil
+}
+
+// contains проверяет, есть ли в дереве узел указанного типа
+func (d *DepSpec) contains(kind DepSpecKind) bool {
+	if d.Kind == kind {
+		return true
+	}
+	for _, c := range d.Children {
+		if c.contains(kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// Satisfied проверяет, выполняется ли ограничение REQUIRED_USE для набора флагов.
+// Пустая группа ?? выполнена всегда, пустые || и ^^ - до EAPI 8;
+// nil вместо EAPI означает правила ранних EAPI.
+func (d *DepSpec) Satisfied(use map[string]bool, eapi *EAPI) bool {
+	if d == nil {
+		return true
+	}
+
+	switch d.Kind {
+	case DepUseFlag:
+		return use[d.Flag] != d.Negate
+	case DepUseConditional:
+		if !d.ConditionMet(use) {
+			return true
+		}
+	}
+
+	met := 0
+	for _, c := range d.Children {
+		if c.Satisfied(use, eapi) {
+			met++
+		}
+	}
+
+	empty := len(d.Children) == 0 && EmptyGroupTrue(eapi)
+	switch d.Kind {
+	case DepAnyOf:
+		return empty || met > 0
+	case DepExactlyOneOf:
+		return empty || met == 1
+	case DepAtMostOneOf:
+		return met <= 1
+	default:
+		return met == len(d.Children)
+	}
+}
+
+// EmptyGroupTrue сообщает, выполнены ли в EAPI пустые группы || ( ) и ^^ ( ) REQUIRED_USE
+func EmptyGroupTrue(eapi *EAPI) bool {
+	return eapi == nil || eapi.EmptyGroupTrue
+}
+
+// Unsatisfied возвращает невыполненные ограничения верхнего уровня REQUIRED_USE
+func (d *DepSpec) Unsatisfied(use map[string]bool, eapi *EAPI) []string {
+	if d == nil {
+		return nil
+	}
+	var failed []string
+	for _, c := range d.Children {
+		if !c.Satisfied(use, eapi) {
+			failed = append(failed, c.nodeString())
+		}
+	}
+	return failed
+}
@@ -0,0 +1,113 @@
+package pkg
+
+import "testing"
+
+func TestParseRequiredUse(t *testing.T) {
+	tests := []struct {
+		spec  string
+		eapi  string
+		valid bool
+	}{
+		{"ssl", "4", true},
+		{"!ssl test? ( debug )", "4", true},
+		{"|| ( ssl gnutls ) ^^ ( qt5 qt6 gtk )", "4", true},
+		{"?? ( ssl gnutls )", "5", true},
+		{"?? ( ssl gnutls )", "4", false},
+		{"ssl", "3", false},
+		{"^^ ( ssl", "4", false},
+		{"ssl? gnutls", "4", false},
+		{"dev-libs/openssl", "4", false},
+		{"!!ssl", "4", false},
+	}
+	for _, tt := range tests {
+		eapi, err := LookupEAPI(tt.eapi)
+		if err != nil {
+			t.Fatalf("LookupEAPI(%q) error: %v", tt.eapi, err)
+		}
+		spec, err := ParseRequiredUse(tt.spec, eapi)
+		if (err == nil) != tt.valid {
+			t.Errorf("ParseRequiredUse(%q, EAPI %s) error = %v, want valid = %v", tt.spec, tt.eapi, err, tt.valid)
+			continue
+		}
+		if tt.valid && spec.String() != tt.spec {
+			t.Errorf("ParseRequiredUse(%q).String() = %q", tt.spec, spec.String())
+		}
+	}
+}
+
+func TestRequiredUseSatisfied(t *testing.T) {
+	tests := []struct {
+		spec string
+		use  []string
+		want bool
+	}{
+		{"ssl", []string{"ssl"}, true},
+		{"ssl", nil, false},
+		{"!ssl", nil, true},
+		{"|| ( ssl gnutls )", []string{"gnutls"}, true},
+		{"|| ( ssl gnutls )", nil, false},
+		{"^^ ( ssl gnutls )", []string{"ssl"}, true},
+		{"^^ ( ssl gnutls )", []string{"ssl", "gnutls"}, false},
+		{"^^ ( ssl gnutls )", nil, false},
+		{"?? ( ssl gnutls )", nil, true},
+		{"?? ( ssl gnutls )", []string{"gnutls"}, true},
+		{"?? ( ssl gnutls )", []string{"ssl", "gnutls"}, false},
+		{"test? ( debug )", nil, true},
+		{"test? ( debug )", []string{"test"}, false},
+		{"!test? ( debug )", nil, false},
+		{"^^ ( ssl ( gnutls nettle ) )", []string{"gnutls"}, false},
+		{"^^ ( ssl ( gnutls nettle ) )", []string{"gnutls", "nettle"}, true},
+		{"^^ ( ssl !gnutls )", nil, true},
+		{"^^ ( ssl !gnutls )", []string{"ssl"}, false},
+		{"|| ( )", nil, true},
+		{"^^ ( )", nil, true},
+	}
+	for _, tt := range tests {
+		spec, err := ParseRequiredUse(tt.spec, nil)
+		if err != nil {
+			t.Fatalf("ParseRequiredUse(%q) error: %v", tt.spec, err)
+		}
+		use := make(map[string]bool)
+		for _, flag := range tt.use {
+			use[flag] = true
+		}
+		if got := spec.Satisfied(use, nil); got != tt.want {
+			t.Errorf("%q.Satisfied(%v) = %v, want %v", tt.spec, tt.use, got, tt.want)
+		}
+		if unmet := spec.Unsatisfied(use, nil); (len(unmet) == 0) != tt.want {
+			t.Errorf("%q.Unsatisfied(%v) = %v", tt.spec, tt.use, unmet)
+		}
+	}
+}
+
+// Пустые группы || ( ) и ^^ ( ) выполнены до EAPI 8, пустая ?? ( ) - всегда
+func TestRequiredUseEmptyGroups(t *testing.T) {
+	tests := []struct {
+		spec string
+		eapi string
+		want bool
+	}{
+		{"|| ( )", "7", true},
+		{"^^ ( )", "7", true},
+		{"|| ( )", "8", false},
+		{"^^ ( )", "8", false},
+		{"?? ( )", "8", true},
+		{"ssl? ( || ( ) )", "8", false},
+		{"|| ( ssl ^^ ( ) )", "8", true},
+		{"!ssl? ( ^^ ( ) )", "8", true},
+	}
+	for _, tt := range tests {
+		eapi, err := LookupEAPI(tt.eapi)
+		if err != nil {
+			t.Fatalf("LookupEAPI(%q) error: %v", tt.eapi, err)
+		}
+		spec, err := ParseRequiredUse(tt.spec, eapi)
+		if err != nil {
+			t.Fatalf("ParseRequiredUse(%q) error: %v", tt.spec, err)
+		}
+		use := map[string]bool{"ssl": true}
+		if got := spec.Satisfied(use, eapi); got != tt.want {
+			t.Errorf("%q.Satisfied(ssl, EAPI %s) = %v, want %v", tt.spec, tt.eapi, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package pkg
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// SuffixType определяет тип суффикса версии (_alpha, _beta, _pre, _rc, _p)
+type SuffixType int
+
+// Порядок констант соответствует порядку сравнения суффиксов в PMS
+const (
+	SuffixAlpha SuffixType = iota
+	SuffixBeta
+	SuffixPre
+	SuffixRC
+	SuffixP
+)
+
+var suffixNames = map[string]SuffixType{
+	"alpha": SuffixAlpha,
+	"beta":  SuffixBeta,
+	"pre":   SuffixPre,
+	"rc":    SuffixRC,
+	"p":     SuffixP,
+}
+
+func (s SuffixType) String() string {
+	switch s {
+	case SuffixAlpha:
+		return "alpha"
+	case SuffixBeta:
+		return "beta"
+	case SuffixPre:
+		return "pre"
+	case SuffixRC:
+		return "rc"
+	case SuffixP:
+		return "p"
+	default:
+		return "unknown"
+	}
+}
+
+// VersionSuffix представляет один суффикс версии, например _rc1
+type VersionSuffix struct {
+	Type   SuffixType
+	Number string // Пустая строка, если число не указано
+}
+
+func (s VersionSuffix) String() string {
+	return "_" + s.Type.String() + s.Number
+}
+
+// Version представляет версию пакета в формате Gentoo (PMS, раздел 3.2)
+type Version struct {
+	Numbers  []string        // Числовые компоненты: 1.02.3 -> ["1", "02", "3"]
+	Letter   byte            // Буквенный суффикс (0, если отсутствует)
+	Suffixes []VersionSuffix // Цепочка суффиксов: _alpha1_p2
+	Revision string          // Ревизия -rN (пустая строка, если не указана)
+}
+
+var versionRe = regexp.MustCompile(`^(\d+(?:\.\d+)*)([a-z]?)((?:_(?:alpha|beta|pre|rc|p)\d*)*)(?:-r(\d+))?$`)
+var suffixRe = regexp.MustCompile(`_(alpha|beta|pre|rc|p)(\d*)`)
+
+// ParseVersion разбирает строку версии по правилам PMS
+func ParseVersion(s string) (*Version, error) {
+	m := versionRe.FindStringSubmatch(s)
+	if m == nil {
+		return nil, fmt.Errorf("invalid version: %q", s)
+	}
+
+	v := &Version{
+		Numbers:  strings.Split(m[1], "."),
+		Revision: m[4],
+	}
+	if m[2] != "" {
+		v.Letter = m[2][0]
+	}
+	for _, sm := range suffixRe.FindAllStringSubmatch(m[3], -1) {
+		v.Suffixes = append(v.Suffixes, VersionSuffix{
+			Type:   suffixNames[sm[1]],
+			Number: sm[2],
+		})
+	}
+	return v, nil
+}
+
+// IsValidVersion проверяет, является ли строка корректной версией Gentoo
+func IsValidVersion(s string) bool {
+	return versionRe.MatchString(s)
+}
+
+// String возвращает строковое представление версии
+func (v *Version) String() string {
+	var sb strings.Builder
+	sb.WriteString(strings.Join(v.Numbers, "."))
+	if v.Letter != 0 {
+		sb.WriteByte(v.Letter)
+	}
+	for _, s := range v.Suffixes {
+		sb.WriteString(s.String())
+	}
+	if v.Revision != "" {
+		sb.WriteString("-r" + v.Revision)
+	}
+	return sb.String()
+}
+
+// WithoutRevision возвращает копию версии без ревизии (используется оператором ~)
+func (v *Version) WithoutRevision() *Version {
+	c := *v
+	c.Revision = ""
+	return &c
+}
+
+// Compare сравнивает версии по алгоритму PMS (раздел 3.3).
+// Возвращает -1, 0 или 1.
+func (v *Version) Compare(other *Version) int {
+	// Алгоритм 3.2: первый компонент всегда сравнивается как целое число
+	if c := compareNumeric(v.Numbers[0], other.Numbers[0]); c != 0 {
+		return c
+	}
+
+	// Алгоритм 3.3: остальные числовые компоненты
+	for i := 1; i < len(v.Numbers) && i < len(other.Numbers); i++ {
+		if c := compareComponent(v.Numbers[i], other.Numbers[i]); c != 0 {
+			return c
+		}
+	}
+	if len(v.Numbers) != len(other.Numbers) {
+		return sign(len(v.Numbers) - len(other.Numbers))
+	}
+
+	// Алгоритм 3.4: буквенный суффикс (отсутствие буквы меньше любой буквы)
+	if v.Letter != other.Letter {
+		return sign(int(v.Letter) - int(other.Letter))
+	}
+
+	// Алгоритм 3.5: суффиксы
+	for i := 0; i < len(v.Suffixes) && i < len(other.Suffixes); i++ {
+		if c := compareSuffix(v.Suffixes[i], other.Suffixes[i]); c != 0 {
+			return c
+		}
+	}
+	if len(v.Suffixes) > len(other.Suffixes) {
+		// Лишний суффикс _p делает версию больше, остальные - меньше
+		if v.Suffixes[len(other.Suffixes)].Type == SuffixP {
+			return 1
+		}
+		return -1
+	}
+	if len(other.Suffixes) > len(v.Suffixes) {
+		if other.Suffixes[len(v.Suffixes)].Type == SuffixP {
+			return -1
+		}
+		return 1
+	}
+
+	// Алгоритм 3.7: ревизия (отсутствие ревизии равно -r0)
+	return compareNumeric(v.Revision, other.Revision)
+}
+
+// HasPrefix проверяет совпадение версии с шаблоном оператора =ver*:
+// указанные в шаблоне компоненты должны совпадать, остальные могут быть любыми
+func (v *Version) HasPrefix(prefix *Version) bool {
+	if len(prefix.Numbers) > len(v.Numbers) {
+		return false
+	}
+	if compareNumeric(v.Numbers[0], prefix.Numbers[0]) != 0 {
+		return false
+	}
+	for i := 1; i < len(prefix.Numbers); i++ {
+		if compareComponent(v.Numbers[i], prefix.Numbers[i]) != 0 {
+			return false
+		}
+	}
+
+	// Если после числовых компонентов в шаблоне ничего нет - совпадение
+	if prefix.Letter == 0 && len(prefix.Suffixes) == 0 && prefix.Revision == "" {
+		return true
+	}
+	if len(prefix.Numbers) != len(v.Numbers) || prefix.Letter != v.Letter {
+		return false
+	}
+
+	if len(prefix.Suffixes) > len(v.Suffixes) {
+		return false
+	}
+	for i, s := range prefix.Suffixes {
+		if compareSuffix(v.Suffixes[i], s) != 0 {
+			return false
+		}
+	}
+
+	if prefix.Revision == "" {
+		return true
+	}
+	return len(prefix.Suffixes) == len(v.Suffixes) && compareNumeric(v.Revision, prefix.Revision) == 0
+}
+
+// compareComponent сравнивает числовые компоненты версии, кроме первого
+func compareComponent(a, b string) int {
+	if strings.HasPrefix(a, "0") || strings.HasPrefix(b, "0") {
+		// Компонент с ведущим нулем сравнивается как строка без хвостовых нулей
+		return strings.Compare(strings.TrimRight(a, "0"), strings.TrimRight(b, "0"))
+	}
+	return compareNumeric(a, b)
+}
+
+func compareSuffix(a, b VersionSuffix) int {
+	if a.Type != b.Type {
+		return sign(int(a.Type) - int(b.Type))
+	}
+	return compareNumeric(a.Number, b.Number)
+}
+
+// compareNumeric сравнивает десятичные числа произвольной длины.
+// Пустая строка считается нулем.
+func compareNumeric(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return sign(len(a) - len(b))
+	}
+	return strings.Compare(a, b)
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	default:
+		return 0
+	}
+}
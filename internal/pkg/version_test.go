@@ -0,0 +1,122 @@
+package pkg
+
+import "testing"
+
+func TestVersionCompare(t *testing.T) {
+	// Пары упорядочены: a < b
+	tests := []struct{ a, b string }{
+		{"1", "2"},
+		{"1.2", "1.10"},
+		{"1.02", "1.1"},
+		{"1.01", "1.1"},
+		{"1.0", "1.0.0"},
+		{"1.2", "1.2.0.1"},
+		{"9", "10"},
+		{"1.2.3", "1.2.3a"},
+		{"1.2.3a", "1.2.3b"},
+		{"1.2.3_alpha", "1.2.3_beta"},
+		{"1.2.3_beta", "1.2.3_pre"},
+		{"1.2.3_pre", "1.2.3_rc"},
+		{"1.2.3_rc1", "1.2.3"},
+		{"1.2.3_rc1", "1.2.3_rc2"},
+		{"1.2.3_rc9", "1.2.3_rc10"},
+		{"1.2.3", "1.2.3_p"},
+		{"1.2.3_p1", "1.2.3_p2"},
+		{"1.2.3_alpha_p1", "1.2.3_alpha_p2"},
+		{"1.2.3_p1_alpha", "1.2.3_p1"},
+		{"1.2.3", "1.2.3-r1"},
+		{"1.2.3-r1", "1.2.3-r2"},
+		{"1.2.3-r9", "1.2.3-r10"},
+		{"1.2.3-r1", "1.2.4"},
+		{"1.2.3_p1", "1.2.4_alpha"},
+		{"12345678901234567890", "12345678901234567891"},
+	}
+	for _, tt := range tests {
+		a, err := ParseVersion(tt.a)
+		if err != nil {
+			t.Fatalf("ParseVersion(%q) error: %v", tt.a, err)
+		}
+		b, err := ParseVersion(tt.b)
+		if err != nil {
+			t.Fatalf("ParseVersion(%q) error: %v", tt.b, err)
+		}
+		if c := a.Compare(b); c != -1 {
+			t.Errorf("Compare(%s, %s) = %d, want -1", tt.a, tt.b, c)
+		}
+		if c := b.Compare(a); c != 1 {
+			t.Errorf("Compare(%s, %s) = %d, want 1", tt.b, tt.a, c)
+		}
+	}
+}
+
+func TestVersionEqual(t *testing.T) {
+	tests := []struct{ a, b string }{
+		{"1.2.3", "1.2.3"},
+		{"1.2.3", "1.2.3-r0"},
+		{"1.2.3-r00", "1.2.3-r0"},
+		{"01", "1"},
+		{"1.10", "1.10"},
+		{"1.2_rc", "1.2_rc0"},
+	}
+	for _, tt := range tests {
+		a, _ := ParseVersion(tt.a)
+		b, _ := ParseVersion(tt.b)
+		if a == nil || b == nil {
+			t.Fatalf("ParseVersion(%q, %q) failed", tt.a, tt.b)
+		}
+		if c := a.Compare(b); c != 0 {
+			t.Errorf("Compare(%s, %s) = %d, want 0", tt.a, tt.b, c)
+		}
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		valid   bool
+	}{
+		{"1", true},
+		{"1.2.3b_alpha4_pre5-r6", true},
+		{"1.2_p", true},
+		{"", false},
+		{"1.", false},
+		{".1", false},
+		{"1.2ab", false},
+		{"1.2_gamma", false},
+		{"1.2-r", false},
+		{"1.2-r1-r2", false},
+		{"v1.2", false},
+	}
+	for _, tt := range tests {
+		v, err := ParseVersion(tt.version)
+		if (err == nil) != tt.valid {
+			t.Errorf("ParseVersion(%q) error = %v, want valid = %v", tt.version, err, tt.valid)
+			continue
+		}
+		if tt.valid && v.String() != tt.version {
+			t.Errorf("ParseVersion(%q).String() = %q", tt.version, v.String())
+		}
+	}
+}
+
+func TestVersionHasPrefix(t *testing.T) {
+	tests := []struct {
+		version, prefix string
+		want            bool
+	}{
+		{"1.2.3", "1.2", true},
+		{"1.2.3", "1", true},
+		{"1.20", "1.2", false},
+		{"1.2", "1.2.3", false},
+		{"1.2.3_rc1-r1", "1.2.3_rc1-r1", true},
+		{"1.2.3_rc1-r1", "1.2.3-r1", false},
+		{"1.2.3a", "1.2.3b", false},
+	}
+	for _, tt := range tests {
+		v, _ := ParseVersion(tt.version)
+		p, _ := ParseVersion(tt.prefix)
+		if got := v.HasPrefix(p); got != tt.want {
+			t.Errorf("%s.HasPrefix(%s) = %v, want %v", tt.version, tt.prefix, got, tt.want)
+		}
+	}
+}
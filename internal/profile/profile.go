@@ -0,0 +1,330 @@
+package profile
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kolkov/gportage/internal/config"
+	"github.com/kolkov/gportage/internal/pkg"
+)
+
+// PackageFlags - запись package.use, package.use.mask или package.use.force
+type PackageFlags struct {
+	Atom  *pkg.Atom
+	Flags []string
+}
+
+// Profile - эффективная конфигурация каскадного профиля
+type Profile struct {
+	Path            string              // Путь к профилю (обычно /etc/portage/make.profile)
+	Nodes           []string            // Каталоги профиля от самого общего к самому частному
+	Vars            map[string]string   // make.defaults с учетом инкрементальных переменных
+	Incremental     map[string][]string // Значения инкрементальных переменных всех узлов до объединения
+	UseForce        []string            // use.force
+	UseMask         []string            // use.mask
+	PackageUseForce []PackageFlags      // package.use.force
+	PackageUseMask  []PackageFlags      // package.use.mask
+	PackageUse      []PackageFlags      // package.use профиля
+	Packages        []string            // packages (записи "*atom" входят в @system)
+	PackageMask     []config.Entry      // package.mask с комментариями, включая записи "-atom"
+}
+
+// Repository описывает репозиторий, профили которого могут входить в каскад
+type Repository struct {
+	Name     string
+	Location string
+	Masters  []string // Имена репозиториев-мастеров
+	Portage2 bool     // profile-formats содержит portage-2
+}
+
+// Load разбирает профиль и всю цепочку его родителей.
+// repos нужны для синтаксиса "repo:path" в файлах parent: он допустим только в профилях
+// репозиториев с profile-formats = portage-2 и может ссылаться лишь на сам репозиторий
+// и его мастеров. Для профилей вне известных репозиториев ограничений нет.
+func Load(path string, repos []*Repository) (*Profile, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid profile %s: %w", path, err)
+	}
+
+	l := &loader{list: repos, repos: make(map[string]*Repository), visiting: make(map[string]bool)}
+	for _, r := range repos {
+		if _, exists := l.repos[r.Name]; !exists {
+			l.repos[r.Name] = r
+		}
+	}
+	if err := l.walk(resolved); err != nil {
+		return nil, err
+	}
+
+	p := &Profile{
+		Path:        path,
+		Nodes:       l.nodes,
+		Vars:        make(map[string]string),
+		Incremental: make(map[string][]string),
+	}
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+type loader struct {
+	list     []*Repository
+	repos    map[string]*Repository
+	nodes    []string
+	visiting map[string]bool
+}
+
+// owner возвращает репозиторий, которому принадлежит узел профиля, или nil
+func (l *loader) owner(dir string) *Repository {
+	root := profilesRoot(dir)
+	if root == "" {
+		return nil
+	}
+	root = realPath(root)
+	for _, r := range l.list {
+		if root == profilesDir(r) {
+			return r
+		}
+	}
+	return nil
+}
+
+// profilesDir возвращает каталог profiles репозитория без символических ссылок
+func profilesDir(r *Repository) string {
+	return realPath(filepath.Join(r.Location, "profiles"))
+}
+
+// realPath раскрывает символические ссылки в пути, если он существует
+func realPath(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return filepath.Clean(path)
+}
+
+// inherits проверяет, что профили репозитория target доступны из репозитория r:
+// это сам r или один из его мастеров, в том числе косвенных
+func (l *loader) inherits(r, target *Repository, seen map[string]bool) bool {
+	if profilesDir(r) == profilesDir(target) {
+		return true
+	}
+	if seen[r.Name] {
+		return false
+	}
+	seen[r.Name] = true
+	for _, name := range r.Masters {
+		if m, ok := l.repos[name]; ok && l.inherits(m, target, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// walk обходит родителей в глубину; родители добавляются раньше потомков
+func (l *loader) walk(dir string) error {
+	if l.visiting[dir] {
+		return fmt.Errorf("profile parent cycle at %s", dir)
+	}
+	l.visiting[dir] = true
+	defer delete(l.visiting, dir)
+
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("profile directory does not exist: %s", dir)
+	}
+
+	entries, err := config.ReadEntries(filepath.Join(dir, "parent"))
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		parent, err := l.resolveParent(dir, e.Fields[0])
+		if err != nil {
+			return fmt.Errorf("%s: %w", e.Source, err)
+		}
+		if err := l.walk(parent); err != nil {
+			return err
+		}
+	}
+
+	l.nodes = append(l.nodes, dir)
+	return nil
+}
+
+// resolveParent преобразует запись файла parent в путь.
+// Поддерживаются относительные пути, "repo:path" и ":path" (profile-formats portage-2).
+func (l *loader) resolveParent(dir, entry string) (string, error) {
+	if name, rel, found := strings.Cut(entry, ":"); found {
+		own := l.owner(dir)
+		if own != nil && !own.Portage2 {
+			return "", fmt.Errorf("parent %q requires profile-formats = portage-2 in repository %s", entry, own.Name)
+		}
+
+		var root string
+		if name == "" {
+			root = profilesRoot(dir)
+			if root == "" {
+				return "", fmt.Errorf("cannot find profiles directory for %q", entry)
+			}
+		} else {
+			r, ok := l.repos[name]
+			if !ok {
+				return "", fmt.Errorf("unknown repository %q in parent %q", name, entry)
+			}
+			if own != nil && !l.inherits(own, r, make(map[string]bool)) {
+				return "", fmt.Errorf("parent %q: repository %s is not a master of %s", entry, name, own.Name)
+			}
+			root = filepath.Join(r.Location, "profiles")
+		}
+		return filepath.Clean(filepath.Join(root, rel)), nil
+	}
+
+	if filepath.IsAbs(entry) {
+		return filepath.Clean(entry), nil
+	}
+	return filepath.Clean(filepath.Join(dir, entry)), nil
+}
+
+// profilesRoot находит каталог profiles репозитория, которому принадлежит узел профиля
+func profilesRoot(dir string) string {
+	for d := dir; d != filepath.Dir(d); d = filepath.Dir(d) {
+		if filepath.Base(d) == "profiles" {
+			return d
+		}
+	}
+	return ""
+}
+
+// load объединяет файлы всех узлов профиля
+func (p *Profile) load() error {
+	var useForce, useMask []string
+
+	for _, node := range p.Nodes {
+		// make.defaults видит значения, заданные родительскими профилями
+		sv := config.NewShellVars(func(name string) (string, bool) {
+			v, ok := p.Vars[name]
+			return v, ok
+		})
+		if err := sv.ParseFile(filepath.Join(node, "make.defaults")); err != nil {
+			return err
+		}
+		for name, value := range sv.Vars {
+			if config.IsIncremental(name) {
+				p.Incremental[name] = append(p.Incremental[name], strings.Fields(value)...)
+				p.Vars[name] = strings.Join(config.StackIncremental(p.Incremental[name]), " ")
+				continue
+			}
+			p.Vars[name] = value
+		}
+
+		var err error
+		if useForce, err = appendFlags(useForce, filepath.Join(node, "use.force")); err != nil {
+			return err
+		}
+		if useMask, err = appendFlags(useMask, filepath.Join(node, "use.mask")); err != nil {
+			return err
+		}
+		for _, f := range []struct {
+			name   string
+			target *[]PackageFlags
+		}{
+			{"package.use.force", &p.PackageUseForce},
+			{"package.use.mask", &p.PackageUseMask},
+			{"package.use", &p.PackageUse},
+		} {
+			flags, err := ReadPackageFlags(filepath.Join(node, f.name))
+			if err != nil {
+				return err
+			}
+			*f.target = append(*f.target, flags...)
+		}
+
+		if p.Packages, err = appendLines(p.Packages, filepath.Join(node, "packages")); err != nil {
+			return err
+		}
+		masks, err := config.ReadEntries(filepath.Join(node, "package.mask"))
+		if err != nil {
+			return err
+		}
+		p.PackageMask = append(p.PackageMask, masks...)
+	}
+
+	p.UseForce = config.StackIncremental(useForce)
+	p.UseMask = config.StackIncremental(useMask)
+	return nil
+}
+
+func appendFlags(flags []string, path string) ([]string, error) {
+	entries, err := config.ReadEntries(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		flags = append(flags, e.Fields...)
+	}
+	return flags, nil
+}
+
+func appendLines(lines []string, path string) ([]string, error) {
+	entries, err := config.ReadEntries(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		lines = append(lines, e.Fields[0])
+	}
+	return lines, nil
+}
+
+// ReadPackageFlags читает файл в формате package.use: атом и список флагов в каждой строке
+func ReadPackageFlags(path string) ([]PackageFlags, error) {
+	entries, err := config.ReadEntries(path)
+	if err != nil {
+		return nil, err
+	}
+	var list []PackageFlags
+	for _, e := range entries {
+		atom, err := pkg.ParseAtom(e.Fields[0])
+		if err != nil {
+			log.Printf("Warning: %s: %v", e.Source, err)
+			continue
+		}
+		list = append(list, PackageFlags{Atom: atom, Flags: e.Fields[1:]})
+	}
+	return list, nil
+}
+
+// Var возвращает значение переменной make.defaults
+func (p *Profile) Var(name string) string {
+	return p.Vars[name]
+}
+
+// Arch возвращает архитектуру профиля (ARCH)
+func (p *Profile) Arch() string {
+	return p.Vars["ARCH"]
+}
+
+// System возвращает атомы @system из файлов packages с учетом записей "-*atom"
+func (p *Profile) System() []string {
+	var system []string
+	for _, line := range p.Packages {
+		switch {
+		case strings.HasPrefix(line, "-*"):
+			atom := line[2:]
+			kept := system[:0]
+			for _, s := range system {
+				if s != atom {
+					kept = append(kept, s)
+				}
+			}
+			system = kept
+		case strings.HasPrefix(line, "*"):
+			system = append(system, line[1:])
+		}
+	}
+	return system
+}
@@ -0,0 +1,95 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeProfile создает узел профиля с файлом parent и make.defaults
+func writeProfile(t *testing.T, dir string, parents ...string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "make.defaults"), []byte("USE=\""+filepath.Base(dir)+"\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if len(parents) > 0 {
+		content := strings.Join(parents, "\n") + "\n"
+		if err := os.WriteFile(filepath.Join(dir, "parent"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadParents(t *testing.T) {
+	root := t.TempDir()
+	gentoo := filepath.Join(root, "gentoo")
+	overlay := filepath.Join(root, "overlay")
+	other := filepath.Join(root, "other")
+	writeProfile(t, filepath.Join(gentoo, "profiles", "base"))
+	writeProfile(t, filepath.Join(gentoo, "profiles", "arch", "amd64"), "../../base")
+	writeProfile(t, filepath.Join(other, "profiles", "base"))
+	writeProfile(t, filepath.Join(overlay, "profiles", "local"))
+
+	tests := []struct {
+		name     string
+		parents  []string
+		portage2 bool
+		masters  []string
+		wantErr  string
+		wantUse  string
+	}{
+		{"relative", []string{"../local"}, false, []string{"gentoo"}, "", "local"},
+		{"master", []string{"gentoo:arch/amd64"}, true, []string{"gentoo"}, "", "amd64"},
+		{"own repository", []string{":local"}, true, nil, "", "local"},
+		{"portage-1", []string{"gentoo:arch/amd64"}, false, []string{"gentoo"}, "requires profile-formats = portage-2", ""},
+		{"not a master", []string{"other:base"}, true, []string{"gentoo"}, "is not a master of overlay", ""},
+		{"unknown repository", []string{"missing:base"}, true, []string{"gentoo"}, "unknown repository", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(overlay, "profiles", strings.ReplaceAll(tt.name, " ", "-"))
+			writeProfile(t, dir, tt.parents...)
+			repos := []*Repository{
+				{Name: "overlay", Location: overlay, Masters: tt.masters, Portage2: tt.portage2},
+				{Name: "gentoo", Location: gentoo},
+				{Name: "other", Location: other},
+			}
+
+			prof, err := Load(dir, repos)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if !slices.Contains(prof.Incremental["USE"], tt.wantUse) {
+				t.Errorf("USE = %v, want %s from parent", prof.Incremental["USE"], tt.wantUse)
+			}
+		})
+	}
+}
+
+func TestLoadOutsideRepository(t *testing.T) {
+	root := t.TempDir()
+	gentoo := filepath.Join(root, "gentoo")
+	writeProfile(t, filepath.Join(gentoo, "profiles", "base"))
+	dir := filepath.Join(root, "local", "profiles", "custom")
+	writeProfile(t, dir, "gentoo:base")
+
+	// Профили вне известных репозиториев могут ссылаться на любой репозиторий
+	prof, err := Load(dir, []*Repository{{Name: "gentoo", Location: gentoo}})
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if len(prof.Nodes) != 2 {
+		t.Errorf("Nodes = %v, want parent and profile", prof.Nodes)
+	}
+}
@@ -0,0 +1,134 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kolkov/gportage/internal/pkg"
+)
+
+// namedRepository - репозиторий в составе CompositeRepository
+type namedRepository struct {
+	name     string
+	priority int
+	repo     Repository
+}
+
+// CompositeRepository объединяет версии пакетов из нескольких репозиториев.
+// Версия из репозитория с большим приоритетом (при равном - добавленного раньше)
+// переопределяет ту же версию из остальных репозиториев.
+type CompositeRepository struct {
+	repos []namedRepository
+}
+
+func NewCompositeRepository() *CompositeRepository {
+	return &CompositeRepository{}
+}
+
+// Add добавляет репозиторий; его имя записывается в Package.Repository загруженных версий
+func (c *CompositeRepository) Add(name string, priority int, r Repository) {
+	c.repos = append(c.repos, namedRepository{name: name, priority: priority, repo: r})
+}
+
+func (c *CompositeRepository) LoadPackages(names []string) ([]*pkg.Package, error) {
+	var packages []*pkg.Package
+	for _, name := range names {
+		p, err := c.LoadPackage(name)
+		if err != nil {
+			return nil, err
+		}
+		packages = append(packages, p)
+	}
+	return packages, nil
+}
+
+func (c *CompositeRepository) LoadPackage(name string) (*pkg.Package, error) {
+	versions, err := c.LoadVersions(name)
+	if err != nil {
+		return nil, err
+	}
+	return versions[0], nil
+}
+
+// LoadVersions возвращает версии пакета из всех репозиториев, отсортированные по убыванию,
+// по одной копии каждой версии из репозитория с наибольшим приоритетом.
+// Отсутствие пакета в части репозиториев не считается ошибкой, другие ошибки возвращаются.
+func (c *CompositeRepository) LoadVersions(name string) ([]*pkg.Package, error) {
+	type candidate struct {
+		p        *pkg.Package
+		priority int
+	}
+	var candidates []candidate
+	var missing []string
+
+	for _, r := range c.repos {
+		versions, err := r.repo.LoadVersions(name)
+		if errors.Is(err, ErrPackageNotFound) {
+			missing = append(missing, fmt.Sprintf("%s: %v", r.name, err))
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("repository %s: %w", r.name, err)
+		}
+		for _, p := range versions {
+			p.Repository = r.name
+			candidates = append(candidates, candidate{p: p, priority: r.priority})
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("%w: %s is not in any repository (%s)", ErrPackageNotFound, name, strings.Join(missing, "; "))
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if cmp := pkg.CompareVersions(candidates[i].p.Version, candidates[j].p.Version); cmp != 0 {
+			return cmp > 0
+		}
+		return candidates[i].priority > candidates[j].priority
+	})
+	packages := make([]*pkg.Package, 0, len(candidates))
+	seen := make(map[string]bool)
+	for _, cand := range candidates {
+		if seen[cand.p.Version] {
+			continue
+		}
+		seen[cand.p.Version] = true
+		packages = append(packages, cand.p)
+	}
+	return packages, nil
+}
+
+// Names возвращает имена репозиториев в порядке добавления
+func (c *CompositeRepository) Names() []string {
+	names := make([]string, 0, len(c.repos))
+	for _, r := range c.repos {
+		names = append(names, r.name)
+	}
+	return names
+}
+
+// ListPackages возвращает имена пакетов всех репозиториев, которые поддерживают перечисление
+func (c *CompositeRepository) ListPackages() ([]string, error) {
+	seen := make(map[string]bool)
+	var names []string
+	for _, r := range c.repos {
+		lister, ok := r.repo.(interface{ ListPackages() ([]string, error) })
+		if !ok {
+			continue
+		}
+		repoNames, err := lister.ListPackages()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", r.name, err)
+		}
+		for _, name := range repoNames {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,96 @@
+package repo
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kolkov/gportage/internal/pkg"
+)
+
+// failingRepository возвращает ошибку при загрузке любого пакета
+type failingRepository struct {
+	MockRepository
+	err error
+}
+
+func (f *failingRepository) LoadVersions(name string) ([]*pkg.Package, error) {
+	return nil, f.err
+}
+
+func TestCompositeLoadVersions(t *testing.T) {
+	gentoo := NewMockRepository()
+	gentoo.AddPackage(pkg.NewPackage("dev-libs/b", "1.0", "0"))
+	gentoo.AddPackage(pkg.NewPackage("dev-libs/b", "2.0", "0"))
+	overlay := NewMockRepository()
+	overlay.AddPackage(pkg.NewPackage("dev-libs/b", "2.0", "0"))
+	overlay.AddPackage(pkg.NewPackage("dev-libs/c", "1.0", "0"))
+
+	c := NewCompositeRepository()
+	c.Add("gentoo", -1000, gentoo)
+	c.Add("overlay", 50, overlay)
+
+	versions, err := c.LoadVersions("dev-libs/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range versions {
+		got = append(got, p.Version+"::"+p.Repository)
+	}
+	want := []string{"2.0::overlay", "1.0::gentoo"}
+	if len(got) != len(want) {
+		t.Fatalf("LoadVersions(dev-libs/b) = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("LoadVersions(dev-libs/b) = %v, want %v", got, want)
+			break
+		}
+	}
+
+	if versions, err := c.LoadVersions("dev-libs/c"); err != nil || len(versions) != 1 {
+		t.Errorf("LoadVersions(dev-libs/c) = %v, %v; want the overlay version", versions, err)
+	}
+	if _, err := c.LoadVersions("dev-libs/missing"); !errors.Is(err, ErrPackageNotFound) {
+		t.Errorf("LoadVersions(dev-libs/missing) error = %v, want ErrPackageNotFound", err)
+	}
+
+	boom := errors.New("read error")
+	c.Add("broken", 0, &failingRepository{err: boom})
+	if _, err := c.LoadVersions("dev-libs/b"); !errors.Is(err, boom) {
+		t.Errorf("LoadVersions with a broken repository: error = %v, want %v", err, boom)
+	}
+}
+
+// Копия версии из репозитория с большим приоритетом переопределяет остальные
+// независимо от порядка добавления; при равном приоритете побеждает добавленный раньше
+func TestCompositeOverridesSameVersion(t *testing.T) {
+	type source struct {
+		name     string
+		priority int
+	}
+	tests := []struct {
+		name    string
+		sources []source
+		want    string
+	}{
+		{"higher priority added first", []source{{"overlay", 50}, {"gentoo", -1000}}, "overlay"},
+		{"higher priority added last", []source{{"gentoo", -1000}, {"overlay", 50}}, "overlay"},
+		{"equal priority", []source{{"gentoo", 0}, {"overlay", 0}}, "gentoo"},
+	}
+	for _, tt := range tests {
+		c := NewCompositeRepository()
+		for _, src := range tt.sources {
+			r := NewMockRepository()
+			r.AddPackage(pkg.NewPackage("dev-libs/b", "2.0", "0"))
+			c.Add(src.name, src.priority, r)
+		}
+		versions, err := c.LoadVersions("dev-libs/b")
+		if err != nil {
+			t.Fatalf("%s: LoadVersions() error: %v", tt.name, err)
+		}
+		if len(versions) != 1 || versions[0].Repository != tt.want {
+			t.Errorf("%s: LoadVersions() = %v, want only 2.0::%s", tt.name, versions, tt.want)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package repo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// inheritRe находит строки inherit в ebuild и eclass
+var inheritRe = regexp.MustCompile(`(?m)^[ \t]*inherit[ \t]+([^\n#;&|]+)`)
+
+// parseInherits возвращает имена eclass из строк inherit
+func parseInherits(content []byte) []string {
+	var names []string
+	for _, m := range inheritRe.FindAllSubmatch(content, -1) {
+		for _, name := range strings.Fields(string(m[1])) {
+			// Пропускаем подстановки вида ${PYTHON_ECLASS}, которые нельзя вычислить без bash
+			if !strings.ContainsAny(name, "$`\"'") {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// eclassDirs возвращает каталоги eclass в порядке возрастания приоритета:
+// сначала мастера, затем сам репозиторий
+func (pr *PortageRepository) eclassDirs() []string {
+	var dirs []string
+	seen := make(map[string]bool)
+	for _, m := range pr.Masters {
+		for _, dir := range m.eclassDirs() {
+			if !seen[dir] {
+				seen[dir] = true
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	own := filepath.Join(pr.Path, "eclass")
+	if !seen[own] {
+		dirs = append(dirs, own)
+	}
+	return dirs
+}
+
+// FindEclass возвращает путь к eclass с учетом мастеров:
+// eclass самого репозитория перекрывает одноименный eclass мастера
+func (pr *PortageRepository) FindEclass(name string) (string, error) {
+	dirs := pr.eclassDirs()
+	for i := len(dirs) - 1; i >= 0; i-- {
+		path := filepath.Join(dirs[i], name+".eclass")
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("eclass %s not found", name)
+}
+
+// Eclasses возвращает все доступные репозиторию eclass: имя -> путь
+func (pr *PortageRepository) Eclasses() (map[string]string, error) {
+	eclasses := make(map[string]string)
+	for _, dir := range pr.eclassDirs() {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() && strings.HasSuffix(e.Name(), ".eclass") {
+				eclasses[strings.TrimSuffix(e.Name(), ".eclass")] = filepath.Join(dir, e.Name())
+			}
+		}
+	}
+	return eclasses, nil
+}
+
+// collectEclasses рекурсивно находит eclass и их md5, включая косвенно унаследованные
+func (pr *PortageRepository) collectEclasses(names []string, result map[string]string) error {
+	for _, name := range names {
+		if _, done := result[name]; done {
+			continue
+		}
+
+		path, err := pr.FindEclass(name)
+		if err != nil {
+			return err
+		}
+		sum, err := fileMD5(path)
+		if err != nil {
+			return err
+		}
+		result[name] = sum
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := pr.collectEclasses(parseInherits(content), result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EclassUsers возвращает версии пакетов (category/package-version), наследующие eclass
+func (pr *PortageRepository) EclassUsers(eclass string) ([]string, error) {
+	names, err := pr.ListPackages()
+	if err != nil {
+		return nil, err
+	}
+
+	var users []string
+	for _, name := range names {
+		versions, err := pr.LoadVersions(name)
+		if err != nil {
+			continue
+		}
+		for _, p := range versions {
+			if p.Inherits(eclass) {
+				users = append(users, p.Name+"-"+p.Version)
+			}
+		}
+	}
+	sort.Strings(users)
+	return users, nil
+}
+
+// StaleEbuilds возвращает ebuild (category/pf), для которых запись md5-cache
+// отсутствует или устарела из-за изменения ebuild или любого из его eclass
+func (pr *PortageRepository) StaleEbuilds() ([]string, error) {
+	names, err := pr.ListPackages()
+	if err != nil {
+		return nil, err
+	}
+
+	var stale []string
+	for _, name := range names {
+		category, pkgName, _ := strings.Cut(name, "/")
+		ebuilds, err := pr.findEbuildFiles(filepath.Join(pr.Path, category, pkgName))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range ebuilds {
+			pf := strings.TrimSuffix(filepath.Base(path), ".ebuild")
+			if _, err := pr.cachedMetadata(category, pf, path); err != nil {
+				stale = append(stale, category+"/"+pf)
+			}
+		}
+	}
+	sort.Strings(stale)
+	return stale, nil
+}
@@ -0,0 +1,94 @@
+package repo
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/kolkov/gportage/internal/config"
+)
+
+// Layout описывает репозиторий по profiles/repo_name и metadata/layout.conf
+type Layout struct {
+	Name           string
+	Masters        []string // nil - мастера не указаны
+	ThinManifests  bool
+	SignCommits    bool
+	ProfileFormats []string
+	CacheFormats   []string // nil - форматы кэша не указаны
+	EAPIsBanned    []string
+}
+
+// LoadLayout читает описание репозитория. Без repo_name имя образуется
+// из имени каталога с префиксом "x-", как в Portage.
+func LoadLayout(path string) (*Layout, error) {
+	l := &Layout{ProfileFormats: []string{"portage-1"}}
+
+	name, err := os.ReadFile(filepath.Join(path, "profiles", "repo_name"))
+	switch {
+	case err == nil && strings.TrimSpace(string(name)) != "":
+		l.Name = strings.TrimSpace(string(name))
+	case err == nil || os.IsNotExist(err):
+		l.Name = "x-" + filepath.Base(path)
+		log.Printf("Warning: %s has no profiles/repo_name, using %s", path, l.Name)
+	default:
+		return nil, err
+	}
+
+	entries, err := config.ReadEntries(filepath.Join(path, "metadata", "layout.conf"))
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		key, value, found := strings.Cut(strings.Join(e.Fields, " "), "=")
+		if !found {
+			return nil, fmt.Errorf("%s: expected key = value", e.Source)
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.TrimSpace(key) {
+		case "masters":
+			l.Masters = strings.Fields(value)
+		case "thin-manifests":
+			l.ThinManifests = value == "true"
+		case "sign-commits":
+			l.SignCommits = value == "true"
+		case "profile-formats":
+			l.ProfileFormats = strings.Fields(value)
+		case "cache-formats":
+			l.CacheFormats = strings.Fields(value)
+		case "eapis-banned":
+			l.EAPIsBanned = strings.Fields(value)
+		}
+	}
+	return l, nil
+}
+
+// EAPIBanned проверяет, запрещен ли EAPI в репозитории
+func (l *Layout) EAPIBanned(eapi string) bool {
+	for _, banned := range l.EAPIsBanned {
+		if banned == eapi {
+			return true
+		}
+	}
+	return false
+}
+
+// HasProfileFormat проверяет поддержку формата профилей (portage-2 разрешает "repo:path" в parent)
+func (l *Layout) HasProfileFormat(format string) bool {
+	for _, f := range l.ProfileFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// UsesMD5Cache проверяет, что кэш метаданных репозитория в формате md5-dict
+// можно использовать: cache-formats не указан или содержит md5-dict
+func (l *Layout) UsesMD5Cache() bool {
+	return l.CacheFormats == nil || slices.Contains(l.CacheFormats, "md5-dict")
+}
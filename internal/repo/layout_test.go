@@ -0,0 +1,92 @@
+package repo
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadLayout(t *testing.T) {
+	tests := []struct {
+		name     string
+		repoName string // Пустая строка - profiles/repo_name отсутствует
+		conf     string
+		want     Layout
+		md5      bool // UsesMD5Cache
+	}{
+		{
+			name:     "defaults",
+			repoName: "gentoo",
+			want:     Layout{Name: "gentoo", ProfileFormats: []string{"portage-1"}},
+			md5:      true,
+		},
+		{
+			name:     "all keys",
+			repoName: "overlay",
+			conf: "masters = gentoo kde\nthin-manifests = true\nsign-commits = true\n" +
+				"profile-formats = portage-2 profile-set\ncache-formats = md5-dict\neapis-banned = 0 1 2\n",
+			want: Layout{
+				Name:           "overlay",
+				Masters:        []string{"gentoo", "kde"},
+				ThinManifests:  true,
+				SignCommits:    true,
+				ProfileFormats: []string{"portage-2", "profile-set"},
+				CacheFormats:   []string{"md5-dict"},
+				EAPIsBanned:    []string{"0", "1", "2"},
+			},
+			md5: true,
+		},
+		{
+			name:     "no masters and no md5-cache",
+			repoName: "standalone",
+			conf:     "masters =\nthin-manifests = false\ncache-formats = pms\n",
+			want: Layout{
+				Name:           "standalone",
+				Masters:        []string{},
+				ProfileFormats: []string{"portage-1"},
+				CacheFormats:   []string{"pms"},
+			},
+		},
+		{
+			name: "missing repo_name",
+			want: Layout{Name: "x-missing repo_name", ProfileFormats: []string{"portage-1"}},
+			md5:  true,
+		},
+	}
+	for _, tt := range tests {
+		root := filepath.Join(t.TempDir(), tt.name)
+		if err := os.MkdirAll(filepath.Join(root, "profiles"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(filepath.Join(root, "metadata"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if tt.repoName != "" {
+			if err := os.WriteFile(filepath.Join(root, "profiles", "repo_name"), []byte(tt.repoName+"\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if tt.conf != "" {
+			if err := os.WriteFile(filepath.Join(root, "metadata", "layout.conf"), []byte(tt.conf), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		l, err := LoadLayout(root)
+		if err != nil {
+			t.Errorf("%s: LoadLayout() error: %v", tt.name, err)
+			continue
+		}
+		if l.Name != tt.want.Name || l.ThinManifests != tt.want.ThinManifests || l.SignCommits != tt.want.SignCommits ||
+			!slices.Equal(l.Masters, tt.want.Masters) || (l.Masters == nil) != (tt.want.Masters == nil) ||
+			!slices.Equal(l.ProfileFormats, tt.want.ProfileFormats) ||
+			!slices.Equal(l.CacheFormats, tt.want.CacheFormats) ||
+			!slices.Equal(l.EAPIsBanned, tt.want.EAPIsBanned) {
+			t.Errorf("%s: LoadLayout() = %+v, want %+v", tt.name, *l, tt.want)
+		}
+		if got := l.UsesMD5Cache(); got != tt.md5 {
+			t.Errorf("%s: UsesMD5Cache() = %v, want %v", tt.name, got, tt.md5)
+		}
+	}
+}
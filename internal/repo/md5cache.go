@@ -0,0 +1,199 @@
+package repo
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Metadata содержит метаданные ebuild в формате md5-cache
+type Metadata struct {
+	Vars     map[string]string // DEPEND, RDEPEND, SLOT, IUSE, KEYWORDS, ...
+	Eclasses map[string]string // Имя eclass -> md5 (_eclasses_)
+	MD5      string            // md5 ebuild-файла (_md5_)
+}
+
+// NewMetadata создает пустые метаданные
+func NewMetadata() *Metadata {
+	return &Metadata{
+		Vars:     make(map[string]string),
+		Eclasses: make(map[string]string),
+	}
+}
+
+// Get возвращает значение переменной метаданных
+func (md *Metadata) Get(key string) string {
+	return md.Vars[key]
+}
+
+// ParseMetadata разбирает запись md5-cache (строки KEY=VALUE)
+func ParseMetadata(r io.Reader) (*Metadata, error) {
+	md := NewMetadata()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("malformed md5-cache line: %q", line)
+		}
+
+		switch key {
+		case "_md5_":
+			md.MD5 = value
+		case "_eclasses_":
+			// Формат: name1<TAB>md5<TAB>name2<TAB>md5...
+			fields := strings.Split(value, "\t")
+			if len(fields)%2 != 0 {
+				return nil, fmt.Errorf("malformed _eclasses_ entry: %q", value)
+			}
+			for i := 0; i < len(fields); i += 2 {
+				md.Eclasses[fields[i]] = fields[i+1]
+			}
+		default:
+			md.Vars[key] = value
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return md, nil
+}
+
+// MD5Cache читает метаданные из каталога metadata/md5-cache/<cat>/<pf>
+type MD5Cache struct {
+	Dir string
+}
+
+func NewMD5Cache(dir string) *MD5Cache {
+	return &MD5Cache{Dir: dir}
+}
+
+// Read загружает запись кэша для category/pf.
+// Если записи нет, возвращается ошибка, удовлетворяющая os.IsNotExist.
+func (c *MD5Cache) Read(category, pf string) (*Metadata, error) {
+	f, err := os.Open(filepath.Join(c.Dir, category, pf))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	md, err := ParseMetadata(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid md5-cache entry %s/%s: %w", category, pf, err)
+	}
+	return md, nil
+}
+
+// Write сохраняет запись кэша в формате md5-cache, атомарно заменяя существующую
+func (c *MD5Cache) Write(category, pf string, md *Metadata) error {
+	dir := filepath.Join(c.Dir, category)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(md.Vars))
+	for key := range md.Vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	for _, key := range keys {
+		if md.Vars[key] != "" {
+			fmt.Fprintf(w, "%s=%s\n", key, md.Vars[key])
+		}
+	}
+
+	if len(md.Eclasses) > 0 {
+		names := make([]string, 0, len(md.Eclasses))
+		for name := range md.Eclasses {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fields := make([]string, 0, 2*len(names))
+		for _, name := range names {
+			fields = append(fields, name, md.Eclasses[name])
+		}
+		fmt.Fprintf(w, "_eclasses_=%s\n", strings.Join(fields, "\t"))
+	}
+	fmt.Fprintf(w, "_md5_=%s\n", md.MD5)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+pf+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, pf))
+}
+
+// Validate проверяет, что запись кэша соответствует ebuild и всем унаследованным eclass.
+// FindEclass возвращает путь к файлу eclass по имени.
+func (md *Metadata) Validate(ebuildPath string, FindEclass func(name string) (string, error)) error {
+	sum, err := fileMD5(ebuildPath)
+	if err != nil {
+		return err
+	}
+	if sum != md.MD5 {
+		return fmt.Errorf("stale cache: ebuild %s changed", filepath.Base(ebuildPath))
+	}
+
+	for name, cached := range md.Eclasses {
+		path, err := FindEclass(name)
+		if err != nil {
+			return fmt.Errorf("stale cache: %w", err)
+		}
+		sum, err := fileMD5(path)
+		if err != nil {
+			return err
+		}
+		if sum != cached {
+			return fmt.Errorf("stale cache: eclass %s changed", name)
+		}
+	}
+	return nil
+}
+
+// fileMD5 вычисляет md5 файла в шестнадцатеричном виде
+func fileMD5(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
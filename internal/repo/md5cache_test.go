@@ -0,0 +1,236 @@
+package repo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		entry   string
+		wantErr bool
+		vars    map[string]string
+		eclass  map[string]string
+		md5     string
+	}{
+		{
+			name:   "full entry",
+			entry:  "EAPI=8\nRDEPEND=>=dev-libs/openssl-3:0= zlib? ( sys-libs/zlib )\nSLOT=0\n_eclasses_=toolchain-funcs\tabc\tflag-o-matic\tdef\n_md5_=0123\n",
+			vars:   map[string]string{"EAPI": "8", "RDEPEND": ">=dev-libs/openssl-3:0= zlib? ( sys-libs/zlib )", "SLOT": "0"},
+			eclass: map[string]string{"toolchain-funcs": "abc", "flag-o-matic": "def"},
+			md5:    "0123",
+		},
+		{
+			name:  "value with equals sign and blank lines",
+			entry: "\nDESCRIPTION=a=b\n\n_md5_=ff\n",
+			vars:  map[string]string{"DESCRIPTION": "a=b"},
+			md5:   "ff",
+		},
+		{name: "line without equals sign", entry: "EAPI=8\ngarbage\n", wantErr: true},
+		{name: "odd _eclasses_ fields", entry: "_eclasses_=toolchain-funcs\tabc\tflag-o-matic\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md, err := ParseMetadata(strings.NewReader(tt.entry))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseMetadata() = %+v, want error", md)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMetadata() error: %v", err)
+			}
+			if len(md.Vars) != len(tt.vars) || len(md.Eclasses) != len(tt.eclass) || md.MD5 != tt.md5 {
+				t.Fatalf("ParseMetadata() = %+v", md)
+			}
+			for k, v := range tt.vars {
+				if md.Vars[k] != v {
+					t.Errorf("%s = %q, want %q", k, md.Vars[k], v)
+				}
+			}
+			for k, v := range tt.eclass {
+				if md.Eclasses[k] != v {
+					t.Errorf("eclass %s = %q, want %q", k, md.Eclasses[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestMD5CacheWriteRead(t *testing.T) {
+	c := NewMD5Cache(t.TempDir())
+	md := NewMetadata()
+	md.Vars["EAPI"] = "8"
+	md.Vars["IUSE"] = "+ssl test"
+	md.Vars["PDEPEND"] = ""
+	md.Eclasses["toolchain-funcs"] = "abc"
+	md.MD5 = "0123"
+
+	if err := c.Write("app-misc", "hello-2.10", md); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Read("app-misc", "hello-2.10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Get("EAPI") != "8" || got.Get("IUSE") != "+ssl test" || got.Eclasses["toolchain-funcs"] != "abc" || got.MD5 != "0123" {
+		t.Errorf("Read() = %+v", got)
+	}
+	if _, ok := got.Vars["PDEPEND"]; ok {
+		t.Errorf("empty PDEPEND was written: %+v", got.Vars)
+	}
+
+	if _, err := c.Read("app-misc", "missing-1.0"); !os.IsNotExist(err) {
+		t.Errorf("Read(missing) error = %v, want not exist", err)
+	}
+}
+
+func TestMetadataValidate(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	ebuild := write("hello-2.10.ebuild", "EAPI=8\ninherit toolchain-funcs\n")
+	eclass := write("toolchain-funcs.eclass", "# eclass\n")
+	findEclass := func(name string) (string, error) {
+		if name != "toolchain-funcs" {
+			return "", os.ErrNotExist
+		}
+		return eclass, nil
+	}
+	ebuildSum, _ := fileMD5(ebuild)
+	eclassSum, _ := fileMD5(eclass)
+
+	tests := []struct {
+		name    string
+		md5     string
+		eclass  map[string]string
+		wantErr string
+	}{
+		{"fresh", ebuildSum, map[string]string{"toolchain-funcs": eclassSum}, ""},
+		{"ebuild changed", "0123", map[string]string{"toolchain-funcs": eclassSum}, "ebuild hello-2.10.ebuild changed"},
+		{"eclass changed", ebuildSum, map[string]string{"toolchain-funcs": "0123"}, "eclass toolchain-funcs changed"},
+		{"eclass removed", ebuildSum, map[string]string{"eutils": eclassSum}, "stale cache"},
+	}
+	for _, tt := range tests {
+		md := NewMetadata()
+		md.MD5 = tt.md5
+		md.Eclasses = tt.eclass
+		err := md.Validate(ebuild, findEclass)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: Validate() error: %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: Validate() error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+// Устаревшая запись кэша заменяется разбором ebuild
+func TestLoadVersionsStaleCache(t *testing.T) {
+	root := t.TempDir()
+	for path, content := range map[string]string{
+		"profiles/repo_name":               "test\n",
+		"app-misc/hello/hello-2.10.ebuild": "EAPI=8\nSLOT=\"0\"\nKEYWORDS=\"amd64\"\n",
+	} {
+		full := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pr, err := NewPortageRepository(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md := NewMetadata()
+	md.Vars["EAPI"] = "8"
+	md.Vars["SLOT"] = "0"
+	md.Vars["KEYWORDS"] = "~amd64"
+	md.MD5 = "0123"
+	if err := pr.cache.Write("app-misc", "hello-2.10", md); err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := pr.LoadVersions("app-misc/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kw := versions[0].Keywords; len(kw) != 1 || kw[0] != "amd64" {
+		t.Errorf("KEYWORDS = %v, want amd64 from the ebuild", kw)
+	}
+
+	// Актуальная запись используется вместо ebuild
+	md.MD5, _ = fileMD5(filepath.Join(root, "app-misc/hello/hello-2.10.ebuild"))
+	if err := pr.cache.Write("app-misc", "hello-2.10", md); err != nil {
+		t.Fatal(err)
+	}
+	versions, err = pr.LoadVersions("app-misc/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kw := versions[0].Keywords; len(kw) != 1 || kw[0] != "~amd64" {
+		t.Errorf("KEYWORDS = %v, want ~amd64 from md5-cache", kw)
+	}
+}
+
+// md5-cache репозитория не читается и не пишется, если cache-formats не содержит md5-dict
+func TestCacheFormats(t *testing.T) {
+	tests := []struct {
+		layout string
+		want   string // KEYWORDS выбранной версии
+	}{
+		{"", "~amd64"},
+		{"cache-formats = md5-dict\n", "~amd64"},
+		{"cache-formats = pms\n", "amd64"},
+		{"cache-formats =\n", "amd64"},
+	}
+	for _, tt := range tests {
+		root := t.TempDir()
+		for path, content := range map[string]string{
+			"profiles/repo_name":               "test\n",
+			"metadata/layout.conf":             tt.layout,
+			"app-misc/hello/hello-2.10.ebuild": "EAPI=8\nSLOT=\"0\"\nKEYWORDS=\"amd64\"\n",
+		} {
+			full := filepath.Join(root, path)
+			if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// Актуальная запись кэша с другими KEYWORDS
+		md := NewMetadata()
+		md.Vars["EAPI"] = "8"
+		md.Vars["SLOT"] = "0"
+		md.Vars["KEYWORDS"] = "~amd64"
+		md.MD5, _ = fileMD5(filepath.Join(root, "app-misc/hello/hello-2.10.ebuild"))
+		if err := NewMD5Cache(filepath.Join(root, "metadata", "md5-cache")).Write("app-misc", "hello-2.10", md); err != nil {
+			t.Fatal(err)
+		}
+
+		pr, err := NewPortageRepository(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		versions, err := pr.LoadVersions("app-misc/hello")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kw := versions[0].Keywords; len(kw) != 1 || kw[0] != tt.want {
+			t.Errorf("layout.conf %q: KEYWORDS = %v, want %s", tt.layout, kw, tt.want)
+		}
+	}
+}
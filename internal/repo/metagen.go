@@ -0,0 +1,420 @@
+package repo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/kolkov/gportage/internal/pkg"
+)
+
+// metadataKeys перечисляет переменные метаданных, которые сохраняются в md5-cache (PMS, раздел 14.3)
+var metadataKeys = []string{
+	"BDEPEND", "DEFINED_PHASES", "DEPEND", "DESCRIPTION", "EAPI", "HOMEPAGE",
+	"IDEPEND", "INHERIT", "IUSE", "KEYWORDS", "LICENSE", "PDEPEND", "PROPERTIES",
+	"RDEPEND", "REQUIRED_USE", "RESTRICT", "SLOT", "SRC_URI",
+}
+
+// metadataDriver - скрипт bash, который загружает ebuild в фазе depend
+// и печатает переменные метаданных в формате KEY=VALUE.
+// Значения, заданные в eclass, накапливаются по правилам PMS (раздел 10.2):
+// PROPERTIES и RESTRICT - только начиная с EAPI 8.
+// Функции ver_cut, ver_rs и ver_test (PMS, раздел 12.3.14) доступны в глобальной области,
+// так как ebuild EAPI 7+ используют их в SRC_URI, S и SLOT.
+const metadataDriver = `
+set -f
+__gp_incremental="IUSE REQUIRED_USE DEPEND BDEPEND RDEPEND PDEPEND IDEPEND"
+__gp_incremental_eapi8="PROPERTIES RESTRICT"
+for __gp_v in ${__gp_incremental} ${__gp_incremental_eapi8}; do
+	eval "__gp_E_${__gp_v}="
+done
+INHERITED=""
+INHERIT=""
+__gp_depth=0
+
+die() {
+	echo "die: $*" >&2
+	exit 1
+}
+
+has() {
+	local needle=$1
+	shift
+	local x
+	for x in "$@"; do
+		[[ ${x} == "${needle}" ]] && return 0
+	done
+	return 1
+}
+
+use() { die "use() called in global scope"; }
+usev() { die "usev() called in global scope"; }
+EXPORT_FUNCTIONS() { :; }
+debug-print() { :; }
+debug-print-function() { :; }
+debug-print-section() { :; }
+einfo() { :; }
+elog() { :; }
+ewarn() { :; }
+eerror() { echo "$*" >&2; }
+
+# Разбивает версию на чередующиеся разделители и компоненты: "", 1, ".", 2, "_", rc, "", 3
+__gp_ver_split() {
+	local v=$1 c
+	__gp_comp=()
+	while [[ -n ${v} ]]; do
+		c=${v%%[a-zA-Z0-9]*}
+		__gp_comp+=("${c}")
+		v=${v:${#c}}
+		if [[ ${v} == [0-9]* ]]; then
+			c=${v%%[^0-9]*}
+		else
+			c=${v%%[^a-zA-Z]*}
+		fi
+		__gp_comp+=("${c}")
+		v=${v:${#c}}
+	done
+}
+
+# Разбирает диапазон N, N- или N-M, ограничивая конец значением $2
+__gp_ver_range() {
+	local range=$1 max=$2
+	[[ ${range} =~ ^([0-9]+)(-([0-9]*))?$ ]] || die "invalid version range: ${range}"
+	__gp_start=${BASH_REMATCH[1]}
+	__gp_end=${BASH_REMATCH[3]}
+	[[ -n ${BASH_REMATCH[2]} ]] || __gp_end=${__gp_start}
+	[[ -n ${__gp_end} ]] || __gp_end=${max}
+	(( __gp_end > max )) && __gp_end=${max}
+	return 0
+}
+
+ver_cut() {
+	local range=$1 v=${2-${PV}} i out=""
+	__gp_ver_split "${v}"
+	__gp_ver_range "${range}" $(( ${#__gp_comp[@]} / 2 ))
+	for (( i = __gp_start * 2 - 1; i <= __gp_end * 2 - 1 && i < ${#__gp_comp[@]}; i++ )); do
+		out+=${__gp_comp[i]}
+	done
+	echo "${out}"
+}
+
+ver_rs() {
+	local v=${PV} i out=""
+	if (( $# % 2 )); then
+		v=${!#}
+		set -- "${@:1:$#-1}"
+	fi
+	__gp_ver_split "${v}"
+	while (( $# > 0 )); do
+		__gp_ver_range "$1" $(( ${#__gp_comp[@]} / 2 ))
+		for (( i = __gp_start * 2; i <= __gp_end * 2 && i < ${#__gp_comp[@]}; i += 2 )); do
+			(( i == 0 )) && [[ -z ${__gp_comp[0]} ]] && continue
+			__gp_comp[i]=$2
+		done
+		shift 2
+	done
+	for (( i = 0; i < ${#__gp_comp[@]}; i++ )); do
+		out+=${__gp_comp[i]}
+	done
+	echo "${out}"
+}
+
+# Сравнивает неотрицательные целые произвольной длины: 1 - меньше, 2 - равно, 3 - больше
+__gp_ver_int() {
+	local a=$1 b=$2
+	while [[ ${a} == 0?* ]]; do a=${a#0}; done
+	while [[ ${b} == 0?* ]]; do b=${b#0}; done
+	(( ${#a} < ${#b} )) && return 1
+	(( ${#a} > ${#b} )) && return 3
+	[[ ${a} < ${b} ]] && return 1
+	[[ ${a} > ${b} ]] && return 3
+	return 2
+}
+
+__gp_ver_suffix_rank() {
+	case $1 in
+		alpha) echo 1 ;;
+		beta) echo 2 ;;
+		pre) echo 3 ;;
+		rc) echo 4 ;;
+		p) echo 5 ;;
+	esac
+}
+
+# Сравнивает версии по алгоритму PMS (раздел 3.3): 1 - меньше, 2 - равно, 3 - больше
+__gp_ver_compare() {
+	local re='^([0-9]+(\.[0-9]+)*)([a-z]?)((_(alpha|beta|pre|rc|p)[0-9]*)*)(-r([0-9]+))?$'
+	local an al as ar bn bl bs br
+	[[ $1 =~ ${re} ]] || die "invalid version: $1"
+	an=${BASH_REMATCH[1]} al=${BASH_REMATCH[3]} as=${BASH_REMATCH[4]} ar=${BASH_REMATCH[8]:-0}
+	[[ $2 =~ ${re} ]] || die "invalid version: $2"
+	bn=${BASH_REMATCH[1]} bl=${BASH_REMATCH[3]} bs=${BASH_REMATCH[4]} br=${BASH_REMATCH[8]:-0}
+
+	local -a ac=(${an//./ }) bc=(${bn//./ }) asx=(${as//_/ }) bsx=(${bs//_/ })
+	local a b i r
+	__gp_ver_int "${ac[0]}" "${bc[0]}"
+	r=$?
+	(( r != 2 )) && return ${r}
+	for (( i = 1; i < ${#ac[@]} && i < ${#bc[@]}; i++ )); do
+		a=${ac[i]} b=${bc[i]}
+		if [[ ${a} == 0* || ${b} == 0* ]]; then
+			while [[ ${a} == *0 ]]; do a=${a%0}; done
+			while [[ ${b} == *0 ]]; do b=${b%0}; done
+			[[ ${a} < ${b} ]] && return 1
+			[[ ${a} > ${b} ]] && return 3
+		else
+			__gp_ver_int "${a}" "${b}"
+			r=$?
+			(( r != 2 )) && return ${r}
+		fi
+	done
+	(( ${#ac[@]} < ${#bc[@]} )) && return 1
+	(( ${#ac[@]} > ${#bc[@]} )) && return 3
+
+	[[ ${al} < ${bl} ]] && return 1
+	[[ ${al} > ${bl} ]] && return 3
+
+	local sa sb
+	for (( i = 0; i < ${#asx[@]} && i < ${#bsx[@]}; i++ )); do
+		sa=${asx[i]%%[0-9]*} sb=${bsx[i]%%[0-9]*}
+		a=$(__gp_ver_suffix_rank "${sa}") b=$(__gp_ver_suffix_rank "${sb}")
+		(( a < b )) && return 1
+		(( a > b )) && return 3
+		a=${asx[i]#${sa}} b=${bsx[i]#${sb}}
+		__gp_ver_int "${a:-0}" "${b:-0}"
+		r=$?
+		(( r != 2 )) && return ${r}
+	done
+	if (( ${#asx[@]} > ${#bsx[@]} )); then
+		[[ ${asx[i]%%[0-9]*} == p ]] && return 3
+		return 1
+	fi
+	if (( ${#asx[@]} < ${#bsx[@]} )); then
+		[[ ${bsx[i]%%[0-9]*} == p ]] && return 1
+		return 3
+	fi
+
+	__gp_ver_int "${ar}" "${br}"
+}
+
+ver_test() {
+	local va=${PVR} r
+	if (( $# == 3 )); then
+		va=$1
+		shift
+	fi
+	__gp_ver_compare "${va}" "$2"
+	r=$?
+	case $1 in
+		-eq) (( r == 2 )) ;;
+		-ne) (( r != 2 )) ;;
+		-lt) (( r == 1 )) ;;
+		-le) (( r != 3 )) ;;
+		-gt) (( r == 3 )) ;;
+		-ge) (( r != 1 )) ;;
+		*) die "ver_test: invalid operator: $1" ;;
+	esac
+}
+
+inherit() {
+	local __gp_e __gp_d __gp_f __gp_v
+	local __gp_vars=${__gp_incremental}
+	case ${EAPI:-0} in
+		0|1|2|3|4|5|6|7) ;;
+		*) __gp_vars+=" ${__gp_incremental_eapi8}" ;;
+	esac
+	for __gp_e in "$@"; do
+		__gp_f=""
+		local IFS=":"
+		for __gp_d in ${__GP_ECLASSDIRS}; do
+			[[ -f ${__gp_d}/${__gp_e}.eclass ]] && __gp_f=${__gp_d}/${__gp_e}.eclass
+		done
+		unset IFS
+		[[ -n ${__gp_f} ]] || die "eclass ${__gp_e} not found"
+
+		# Сохраняем значения ebuild и очищаем переменные перед загрузкой eclass
+		for __gp_v in ${__gp_vars}; do
+			local __gp_saved_${__gp_v}="${!__gp_v}"
+			local __gp_isset_${__gp_v}="${!__gp_v+set}"
+			unset ${__gp_v}
+		done
+
+		# INHERIT содержит только eclass, унаследованные непосредственно ebuild
+		(( __gp_depth == 0 )) && INHERIT+=" ${__gp_e}"
+		local ECLASS=${__gp_e}
+		(( __gp_depth++ ))
+		source "${__gp_f}" || die "failed to source ${__gp_e}.eclass"
+		(( __gp_depth-- ))
+
+		for __gp_v in ${__gp_vars}; do
+			eval "__gp_E_${__gp_v}+=\" \${${__gp_v}}\""
+			eval "__gp_isset=\${__gp_isset_${__gp_v}}"
+			if [[ -n ${__gp_isset} ]]; then
+				eval "${__gp_v}=\${__gp_saved_${__gp_v}}"
+			else
+				unset ${__gp_v}
+			fi
+		done
+
+		has "${__gp_e}" ${INHERITED} || INHERITED+=" ${__gp_e}"
+	done
+}
+
+source "${EBUILD}" || die "failed to source ebuild"
+
+for __gp_v in ${__gp_incremental} ${__gp_incremental_eapi8}; do
+	eval "${__gp_v}=\"\${${__gp_v}} \${__gp_E_${__gp_v}}\""
+done
+
+DEFINED_PHASES=""
+for __gp_f in pretend setup unpack prepare configure compile test install preinst postinst prerm postrm config info nofetch; do
+	__gp_fn=src_${__gp_f}
+	case ${__gp_f} in
+		pretend|setup|preinst|postinst|prerm|postrm|config|info|nofetch) __gp_fn=pkg_${__gp_f} ;;
+	esac
+	declare -F "${__gp_fn}" >/dev/null && DEFINED_PHASES+=" ${__gp_f}"
+done
+: ${EAPI:=0}
+
+for __gp_v in ${__GP_KEYS} INHERITED; do
+	__gp_val=${!__gp_v}
+	__gp_val=${__gp_val//$'\n'/ }
+	__gp_val=${__gp_val//$'\t'/ }
+	printf '%s=%s\n' "${__gp_v}" "${__gp_val}"
+done
+`
+
+// MetadataGenerator получает метаданные ebuild, выполняя его в отдельном процессе bash
+// с минимальным окружением, без сети и с ограничением по времени
+type MetadataGenerator struct {
+	Bash    string        // Путь к bash
+	Timeout time.Duration // Максимальное время обработки одного ebuild
+	Isolate bool          // Запускать bash в отдельном сетевом пространстве имен
+}
+
+// NewMetadataGenerator создает генератор с сетевой изоляцией через unshare.
+// Если изоляция недоступна, возвращается ошибка; allowNetwork разрешает
+// в этом случае выполнять ebuild с доступом к сети.
+func NewMetadataGenerator(allowNetwork bool) (*MetadataGenerator, error) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		return nil, fmt.Errorf("bash not found: %w", err)
+	}
+
+	g := &MetadataGenerator{
+		Bash:    bash,
+		Timeout: 30 * time.Second,
+	}
+
+	if err := exec.Command("unshare", "--net", "--map-root-user", "true").Run(); err == nil {
+		g.Isolate = true
+	} else if allowNetwork {
+		log.Printf("Warning: network namespaces unavailable, generating metadata without network isolation: %v", err)
+	} else {
+		return nil, fmt.Errorf("network isolation is unavailable (unshare --net failed: %v)", err)
+	}
+
+	return g, nil
+}
+
+// Generate загружает ebuild category/pf и возвращает его метаданные.
+// eclassDirs перечисляются по возрастанию приоритета, FindEclass используется для подсчета md5.
+func (g *MetadataGenerator) Generate(ebuildPath, category, pf string, eclassDirs []string, FindEclass func(name string) (string, error)) (*Metadata, error) {
+	pn, version, ok := pkg.SplitPackageVersion(pf)
+	if !ok {
+		return nil, fmt.Errorf("invalid ebuild name: %s", pf)
+	}
+	pv, pr := version, "r0"
+	if base, rev, found := strings.Cut(version, "-r"); found {
+		pv, pr = base, "r"+rev
+	}
+
+	workDir, err := os.MkdirTemp("", "gportage-depend-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(workDir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), g.Timeout)
+	defer cancel()
+
+	args := []string{"--noprofile", "--norc", "-c", metadataDriver, "gportage-depend"}
+	name := g.Bash
+	if g.Isolate {
+		args = append([]string{"--net", "--map-root-user", g.Bash}, args...)
+		name = "unshare"
+	}
+
+	// По таймауту завершается вся группа процессов: потомки bash (например, подоболочки)
+	// иначе удерживают stdout открытым и Run не возвращается
+	cmd := exec.CommandContext(ctx, name, args...)
+	setProcessGroup(cmd)
+	cmd.WaitDelay = time.Second
+	cmd.Dir = workDir
+	cmd.Env = []string{
+		"PATH=/usr/bin:/bin",
+		"LC_ALL=C",
+		"HOME=" + workDir,
+		"TMPDIR=" + workDir,
+		"EBUILD=" + ebuildPath,
+		"EBUILD_PHASE=depend",
+		"CATEGORY=" + category,
+		"P=" + pn + "-" + pv,
+		"PN=" + pn,
+		"PV=" + pv,
+		"PR=" + pr,
+		"PVR=" + version,
+		"PF=" + pf,
+		"__GP_ECLASSDIRS=" + strings.Join(eclassDirs, ":"),
+		"__GP_KEYS=" + strings.Join(metadataKeys, " "),
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("metadata generation for %s/%s timed out after %s", category, pf, g.Timeout)
+		}
+		return nil, fmt.Errorf("metadata generation for %s/%s failed: %w: %s", category, pf, err, strings.TrimSpace(stderr.String()))
+	}
+
+	md, err := ParseMetadata(&stdout)
+	if err != nil {
+		return nil, err
+	}
+
+	// Нормализуем пробелы и удаляем пустые значения
+	for key, value := range md.Vars {
+		value = strings.Join(strings.Fields(value), " ")
+		if value == "" {
+			delete(md.Vars, key)
+			continue
+		}
+		md.Vars[key] = value
+	}
+
+	// Контрольные суммы ebuild и всех унаследованных eclass
+	if md.MD5, err = fileMD5(ebuildPath); err != nil {
+		return nil, err
+	}
+	for _, eclass := range strings.Fields(md.Vars["INHERITED"]) {
+		path, err := FindEclass(eclass)
+		if err != nil {
+			return nil, err
+		}
+		if md.Eclasses[eclass], err = fileMD5(path); err != nil {
+			return nil, err
+		}
+	}
+	delete(md.Vars, "INHERITED")
+
+	return md, nil
+}
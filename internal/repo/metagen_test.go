@@ -0,0 +1,133 @@
+package repo
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateTimeoutKillsChildren(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not found")
+	}
+
+	dir := t.TempDir()
+	ebuild := filepath.Join(dir, "foo-1.0.ebuild")
+	if err := os.WriteFile(ebuild, []byte("EAPI=8\n( sleep 8 )\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	g := &MetadataGenerator{Bash: bash, Timeout: time.Second}
+	start := time.Now()
+	_, err = g.Generate(ebuild, "app-misc", "foo-1.0", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("Generate() error = %v, want timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 4*time.Second {
+		t.Errorf("Generate() returned after %s, want about %s", elapsed, g.Timeout)
+	}
+}
+
+func TestGenerateVersionHelpers(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not found")
+	}
+
+	tests := []struct {
+		expr string // Выражение bash, значение которого попадает в DESCRIPTION
+		want string
+	}{
+		{`$(ver_cut 1-2)`, "1.2"},
+		{`$(ver_cut 3)`, "3"},
+		{`$(ver_cut 4-)`, "rc4"},
+		{`$(ver_cut 1-9)`, "1.2.3_rc4"},
+		{`$(ver_cut 2 5.6b)`, "6"},
+		{`$(ver_rs 1 -)`, "1-2.3_rc4"},
+		{`$(ver_rs 1-2 _)`, "1_2_3_rc4"},
+		{`$(ver_rs 1 '' 2 - 2024.01.02)`, "202401-02"},
+		{`$(ver_rs 0 v 1.0)`, "1.0"},
+		{`$(ver_test -lt 1.2.3 && echo yes)`, "yes"},
+		{`$(ver_test -ge 1.2.3_rc4-r1 && echo yes)`, "yes"},
+		{`$(ver_test -gt 1.2.3_rc4-r1 || echo no)`, "no"},
+		{`$(ver_test 1.0 -eq 1.0.0 || echo no)`, "no"},
+		{`$(ver_test 1.01 -lt 1.1 && echo yes)`, "yes"},
+		{`$(ver_test 1.010 -eq 1.01 && echo yes)`, "yes"},
+		{`$(ver_test 1.2a -gt 1.2 && echo yes)`, "yes"},
+		{`$(ver_test 1.2_p1 -gt 1.2 && echo yes)`, "yes"},
+		{`$(ver_test 1.2_pre1 -lt 1.2 && echo yes)`, "yes"},
+		{`$(ver_test 1.2_alpha_p1 -lt 1.2_beta && echo yes)`, "yes"},
+		{`$(ver_test 100000000000000000001 -gt 99999999999999999999 && echo yes)`, "yes"},
+		{`$(ver_test 1.2-r10 -gt 1.2-r9 && echo yes)`, "yes"},
+	}
+
+	dir := t.TempDir()
+	ebuild := filepath.Join(dir, "foo-1.2.3_rc4-r1.ebuild")
+	g := &MetadataGenerator{Bash: bash, Timeout: 10 * time.Second}
+	for _, tt := range tests {
+		content := "EAPI=8\nDESCRIPTION=\"" + tt.expr + "\"\nSLOT=0\n"
+		if err := os.WriteFile(ebuild, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		md, err := g.Generate(ebuild, "app-misc", "foo-1.2.3_rc4-r1", nil, nil)
+		if err != nil {
+			t.Errorf("%s: Generate() error: %v", tt.expr, err)
+			continue
+		}
+		if got := md.Vars["DESCRIPTION"]; got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateEclassAccumulation(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not found")
+	}
+
+	dir := t.TempDir()
+	eclass := filepath.Join(dir, "foo.eclass")
+	if err := os.WriteFile(eclass, []byte("IUSE=\"doc\"\nRESTRICT=\"test\"\nPROPERTIES=\"live\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	findEclass := func(name string) (string, error) {
+		return filepath.Join(dir, name+".eclass"), nil
+	}
+
+	// До EAPI 8 PROPERTIES и RESTRICT из eclass - обычные переменные, которые ebuild может заменить
+	tests := []struct {
+		eapi       string
+		iuse       string
+		restrict   string
+		properties string
+	}{
+		{eapi: "7", iuse: "ssl doc", restrict: "mirror", properties: "live"},
+		{eapi: "8", iuse: "ssl doc", restrict: "mirror test", properties: "live"},
+	}
+	for _, tt := range tests {
+		ebuild := filepath.Join(dir, "foo-1.0.ebuild")
+		content := "EAPI=" + tt.eapi + "\ninherit foo\nIUSE=\"ssl\"\nRESTRICT=\"mirror\"\nSLOT=0\n"
+		if err := os.WriteFile(ebuild, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		g := &MetadataGenerator{Bash: bash, Timeout: 10 * time.Second}
+		md, err := g.Generate(ebuild, "app-misc", "foo-1.0", []string{dir}, findEclass)
+		if err != nil {
+			t.Fatalf("EAPI %s: Generate() error: %v", tt.eapi, err)
+		}
+		if got := md.Vars["IUSE"]; got != tt.iuse {
+			t.Errorf("EAPI %s: IUSE = %q, want %q", tt.eapi, got, tt.iuse)
+		}
+		if got := md.Vars["RESTRICT"]; got != tt.restrict {
+			t.Errorf("EAPI %s: RESTRICT = %q, want %q", tt.eapi, got, tt.restrict)
+		}
+		if got := md.Vars["PROPERTIES"]; got != tt.properties {
+			t.Errorf("EAPI %s: PROPERTIES = %q, want %q", tt.eapi, got, tt.properties)
+		}
+	}
+}
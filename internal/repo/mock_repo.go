@@ -7,11 +7,13 @@ import (
 )
 
 type MockRepository struct {
-	packages map[string]*pkg.Package
+	packages map[string][]*pkg.Package // name -> версии по убыванию
 }
 
 func NewMockRepository() *MockRepository {
-	packages := make(map[string]*pkg.Package)
+	m := &MockRepository{
+		packages: make(map[string][]*pkg.Package),
+	}
 
 	// Создаем пакет hello
 	hello := pkg.NewPackage("app-misc/hello", "2.10", "0")
@@ -21,11 +23,10 @@ func NewMockRepository() *MockRepository {
 		Version: pkg.NewVersionConstraint(pkg.OpGreaterEqual, "1.2.13"),
 	})
 
-	// Создаем пакет zlib
-	zlib := pkg.NewPackage("sys-libs/zlib", "1.2.13", "0")
-
-	packages["app-misc/hello"] = hello
-	packages["sys-libs/zlib"] = zlib
+	// Создаем несколько версий zlib
+	m.AddPackage(hello)
+	m.AddPackage(pkg.NewPackage("sys-libs/zlib", "1.2.13", "0"))
+	m.AddPackage(pkg.NewPackage("sys-libs/zlib", "1.1.4", "0"))
 
 	// Добавляем конфликтующий пакет
 	conflict := pkg.NewPackage("conflict/example", "1.0", "0")
@@ -34,39 +35,57 @@ func NewMockRepository() *MockRepository {
 		Name:    "sys-libs/zlib",
 		Version: pkg.NewVersionConstraint(pkg.OpLess, "1.2.0"), // Конфликтующая версия
 	})
-	packages["conflict/example"] = conflict
+	m.AddPackage(conflict)
 
-	return &MockRepository{
-		packages: packages,
-	}
+	return m
 }
 
 func (m *MockRepository) LoadPackages(names []string) ([]*pkg.Package, error) {
 	result := make([]*pkg.Package, 0, len(names))
 	for _, name := range names {
-		if pkg, exists := m.packages[name]; exists {
-			// Создаем копию пакета
-			copyPkg := *pkg
-			result = append(result, &copyPkg)
-		} else {
-			return nil, fmt.Errorf("package %s not found", name)
+		p, err := m.LoadPackage(name)
+		if err != nil {
+			return nil, err
 		}
+		result = append(result, p)
 	}
 	return result, nil
 }
 
 func (m *MockRepository) LoadPackage(name string) (*pkg.Package, error) {
-	if pkg, exists := m.packages[name]; exists {
-		// Создаем копию пакета
-		copyPkg := *pkg
-		return &copyPkg, nil
+	versions, err := m.LoadVersions(name)
+	if err != nil {
+		return nil, err
 	}
-	return nil, fmt.Errorf("package %s not found", name)
+	return versions[0], nil
+}
+
+func (m *MockRepository) LoadVersions(name string) ([]*pkg.Package, error) {
+	versions, exists := m.packages[name]
+	if !exists || len(versions) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrPackageNotFound, name)
+	}
+
+	// Создаем копии пакетов
+	result := make([]*pkg.Package, 0, len(versions))
+	for _, p := range versions {
+		copyPkg := *p
+		result = append(result, &copyPkg)
+	}
+	return result, nil
 }
 
 func (m *MockRepository) AddPackage(p *pkg.Package) error {
-	// Создаем копию перед сохранением
+	// Создаем копию перед сохранением, заменяя существующую версию
 	copyPkg := *p
-	m.packages[p.Name] = &copyPkg
+	versions := m.packages[p.Name]
+	for i, existing := range versions {
+		if existing.Version == p.Version {
+			versions[i] = &copyPkg
+			return nil
+		}
+	}
+	m.packages[p.Name] = append(versions, &copyPkg)
+	pkg.SortByVersion(m.packages[p.Name])
 	return nil
 }
@@ -1,6 +1,7 @@
 package repo
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -12,8 +13,18 @@ import (
 	"github.com/kolkov/gportage/internal/pkg"
 )
 
+// ErrEAPIBanned возвращается для ebuild с EAPI из eapis-banned в layout.conf
+var ErrEAPIBanned = errors.New("EAPI is banned")
+
+// ErrInvalidMetadata возвращается для ebuild, метаданные которого не удалось разобрать
+var ErrInvalidMetadata = errors.New("invalid ebuild metadata")
+
 type PortageRepository struct {
-	Path string
+	Path      string
+	Masters   []*PortageRepository // Репозитории-мастера, у которых наследуются eclass
+	Generator *MetadataGenerator   // Если задан, недостающие метаданные генерируются через bash
+	Layout    *Layout              // repo_name и metadata/layout.conf
+	cache     *MD5Cache
 }
 
 func NewPortageRepository(path string) (*PortageRepository, error) {
@@ -26,9 +37,23 @@ func NewPortageRepository(path string) (*PortageRepository, error) {
 		return nil, fmt.Errorf("repository directory does not exist: %s", absPath)
 	}
 
-	return &PortageRepository{
-		Path: absPath,
-	}, nil
+	layout, err := LoadLayout(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository layout in %s: %w", absPath, err)
+	}
+
+	pr := &PortageRepository{Path: absPath, Layout: layout}
+	if layout.UsesMD5Cache() {
+		pr.cache = NewMD5Cache(filepath.Join(absPath, "metadata", "md5-cache"))
+	} else {
+		log.Printf("Repository %s does not provide md5-cache (cache-formats: %s)", layout.Name, strings.Join(layout.CacheFormats, " "))
+	}
+	return pr, nil
+}
+
+// Name возвращает имя репозитория из profiles/repo_name
+func (pr *PortageRepository) Name() string {
+	return pr.Layout.Name
 }
 
 func (pr *PortageRepository) LoadPackages(names []string) ([]*pkg.Package, error) {
@@ -46,6 +71,16 @@ func (pr *PortageRepository) LoadPackages(names []string) ([]*pkg.Package, error
 }
 
 func (pr *PortageRepository) LoadPackage(name string) (*pkg.Package, error) {
+	versions, err := pr.LoadVersions(name)
+	if err != nil {
+		return nil, err
+	}
+	// Версии отсортированы по убыванию, первая - наибольшая
+	return versions[0], nil
+}
+
+// LoadVersions загружает все версии пакета, отсортированные по убыванию
+func (pr *PortageRepository) LoadVersions(name string) ([]*pkg.Package, error) {
 	category, pkgName, found := strings.Cut(name, "/")
 	if !found {
 		return nil, fmt.Errorf("invalid package name: %s", name)
@@ -58,33 +93,227 @@ func (pr *PortageRepository) LoadPackage(name string) (*pkg.Package, error) {
 	log.Printf("Looking for package in: %s", absPath)
 
 	files, err := ioutil.ReadDir(pkgDir)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrPackageNotFound, name)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("error reading package directory: %w", err)
 	}
 
-	var versions []string
+	var packages []*pkg.Package
+	var rejected []string // Ebuild с неподдерживаемым или запрещенным EAPI и некорректными метаданными
 	for _, file := range files {
 		if file.IsDir() || !strings.HasSuffix(file.Name(), ".ebuild") {
 			continue
 		}
 
-		if strings.HasSuffix(file.Name(), ".ebuild") {
-			version := strings.TrimSuffix(file.Name(), ".ebuild")
-			version = strings.TrimPrefix(version, pkgName+"-")
-			versions = append(versions, version)
+		version := strings.TrimSuffix(file.Name(), ".ebuild")
+		version = strings.TrimPrefix(version, pkgName+"-")
+		if !pkg.IsValidVersion(version) {
+			log.Printf("Skipping ebuild with invalid version: %s", file.Name())
+			continue
+		}
+
+		p, err := pr.loadEbuild(name, version, filepath.Join(pkgDir, file.Name()))
+		if errors.Is(err, pkg.ErrUnsupportedEAPI) || errors.Is(err, ErrEAPIBanned) || errors.Is(err, ErrInvalidMetadata) {
+			log.Printf("Skipping ebuild: %v", err)
+			rejected = append(rejected, err.Error())
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		packages = append(packages, p)
+	}
+
+	if len(packages) == 0 && len(rejected) > 0 {
+		return nil, fmt.Errorf("%w: no usable ebuilds for %s: %s", ErrPackageNotFound, name, strings.Join(rejected, "; "))
+	}
+	if len(packages) == 0 {
+		return nil, fmt.Errorf("%w: no ebuilds found for %s", ErrPackageNotFound, name)
+	}
+
+	pkg.SortByVersion(packages)
+	return packages, nil
+}
+
+// loadEbuild загружает метаданные ebuild из md5-cache, а при отсутствии
+// или устаревании кэша - упрощенным разбором самого ebuild
+func (pr *PortageRepository) loadEbuild(name, version, path string) (*pkg.Package, error) {
+	category, pkgName, _ := strings.Cut(name, "/")
+	pf := pkgName + "-" + version
+
+	md, err := pr.cachedMetadata(category, pf, path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Ignoring md5-cache entry for %s: %v", name+"-"+version, err)
+		}
+		md, err = pr.regenerate(category, pf, path)
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	if len(versions) == 0 {
-		return nil, fmt.Errorf("no ebuilds found for %s", name)
+	eapi := md.Get("EAPI")
+	if eapi == "" {
+		eapi = "0"
+	}
+	if pr.Layout.EAPIBanned(eapi) {
+		return nil, fmt.Errorf("%s-%s: %w: EAPI %s in repository %s", name, version, ErrEAPIBanned, eapi, pr.Name())
 	}
 
-	// Берем последнюю версию (для примера)
-	latestVersion := versions[len(versions)-1]
-	return pr.parseEbuild(name, filepath.Join(pkgDir, pkgName+"-"+latestVersion+".ebuild")) // Передаем имя пакета
+	return NewPackageFromMetadata(name, version, md)
 }
 
-func (pr *PortageRepository) parseEbuild(name, path string) (*pkg.Package, error) {
+// cachedMetadata возвращает актуальную запись md5-cache для ebuild path.
+// Если репозиторий не использует md5-cache, возвращается os.ErrNotExist.
+func (pr *PortageRepository) cachedMetadata(category, pf, path string) (*Metadata, error) {
+	if pr.cache == nil {
+		return nil, os.ErrNotExist
+	}
+	md, err := pr.cache.Read(category, pf)
+	if err == nil {
+		err = md.Validate(path, pr.FindEclass)
+	}
+	return md, err
+}
+
+// regenerate получает метаданные через генератор и сохраняет их в кэш.
+// Без генератора используется упрощенный разбор ebuild.
+func (pr *PortageRepository) regenerate(category, pf, path string) (*Metadata, error) {
+	if pr.Generator == nil {
+		return pr.parseEbuild(path)
+	}
+
+	md, err := pr.Generator.Generate(path, category, pf, pr.eclassDirs(), pr.FindEclass)
+	if err != nil {
+		log.Printf("Warning: %v; falling back to ebuild parser", err)
+		return pr.parseEbuild(path)
+	}
+
+	if pr.cache == nil {
+		return md, nil
+	}
+	if err := pr.cache.Write(category, pf, md); err != nil {
+		log.Printf("Warning: failed to write md5-cache entry for %s/%s: %v", category, pf, err)
+	}
+	return md, nil
+}
+
+// SetCacheDir задает каталог md5-cache (по умолчанию metadata/md5-cache репозитория,
+// если cache-formats в layout.conf допускает md5-dict)
+func (pr *PortageRepository) SetCacheDir(dir string) {
+	pr.cache = NewMD5Cache(dir)
+}
+
+// RegenerateMetadata генерирует записи md5-cache для всех ebuild репозитория,
+// пропуская актуальные. Возвращает число обновленных записей.
+func (pr *PortageRepository) RegenerateMetadata() (int, error) {
+	if pr.Generator == nil {
+		return 0, fmt.Errorf("metadata generator is not configured")
+	}
+	if pr.cache == nil {
+		return 0, fmt.Errorf("repository %s does not use md5-cache (cache-formats in metadata/layout.conf); use --cache-dir", pr.Name())
+	}
+
+	stale, err := pr.StaleEbuilds()
+	if err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for _, cpv := range stale {
+		category, pf, _ := strings.Cut(cpv, "/")
+		pkgName, _, _ := pkg.SplitPackageVersion(pf)
+		path := filepath.Join(pr.Path, category, pkgName, pf+".ebuild")
+
+		md, err := pr.Generator.Generate(path, category, pf, pr.eclassDirs(), pr.FindEclass)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			continue
+		}
+		if err := pr.cache.Write(category, pf, md); err != nil {
+			return updated, err
+		}
+		updated++
+	}
+	return updated, nil
+}
+
+// ListPackages возвращает имена всех пакетов репозитория в виде category/package
+func (pr *PortageRepository) ListPackages() ([]string, error) {
+	categories, err := pr.categories()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, cat := range categories {
+		packages, err := os.ReadDir(filepath.Join(pr.Path, cat))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, p := range packages {
+			if p.IsDir() {
+				names = append(names, cat+"/"+p.Name())
+			}
+		}
+	}
+	return names, nil
+}
+
+// categories читает список категорий из profiles/categories,
+// а при его отсутствии - из каталогов верхнего уровня
+func (pr *PortageRepository) categories() ([]string, error) {
+	if content, err := os.ReadFile(filepath.Join(pr.Path, "profiles", "categories")); err == nil {
+		return strings.Fields(string(content)), nil
+	}
+
+	entries, err := os.ReadDir(pr.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var categories []string
+	for _, e := range entries {
+		switch e.Name() {
+		case "eclass", "licenses", "metadata", "profiles", "scripts":
+			continue
+		}
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+			categories = append(categories, e.Name())
+		}
+	}
+	return categories, nil
+}
+
+// ebuildVarRe находит однострочные и многострочные присваивания VAR="..."
+var ebuildVarRe = regexp.MustCompile(`(?m)^([A-Z_][A-Z0-9_]*)="([^"]*)"`)
+
+// eapiRe находит присваивание EAPI в кавычках или без (PMS, раздел 7.3.1)
+var eapiRe = regexp.MustCompile(`^[ \t]*EAPI=['"]?([A-Za-z0-9+_.-]*)['"]?[ \t]*(#.*)?$`)
+
+// parseEAPI возвращает EAPI ebuild: присваивание должно быть первой строкой,
+// не считая пустых строк и комментариев. Без него EAPI равен 0.
+func parseEAPI(content []byte) string {
+	for _, line := range strings.Split(string(content), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if m := eapiRe.FindStringSubmatch(line); m != nil {
+			return m[1]
+		}
+		break
+	}
+	return "0"
+}
+
+// parseEbuild извлекает метаданные упрощенным разбором ebuild без выполнения bash
+func (pr *PortageRepository) parseEbuild(path string) (*Metadata, error) {
 	log.Printf("Parsing ebuild: %s", path)
 	content, err := os.ReadFile(path)
 	if err != nil {
@@ -92,62 +321,137 @@ func (pr *PortageRepository) parseEbuild(name, path string) (*pkg.Package, error
 		return nil, err
 	}
 
-	// Упрощенный парсер ebuild
-	p := &pkg.Package{
-		Name:     name, // Исправлено! Устанавливаем имя пакета
-		Version:  "",
-		Slot:     pkg.Slot{Name: "0"},
-		UseFlags: make(map[string]bool),
-		Deps:     make([]pkg.Constraint, 0),
-		Provides: make([]pkg.Constraint, 0),
+	md := NewMetadata()
+	for _, m := range ebuildVarRe.FindAllStringSubmatch(string(content), -1) {
+		md.Vars[m[1]] = m[2]
+	}
+	md.Vars["EAPI"] = parseEAPI(content)
+
+	// Собираем унаследованные eclass и их контрольные суммы
+	direct := parseInherits(content)
+	md.Vars["INHERIT"] = strings.Join(direct, " ")
+	if err := pr.collectEclasses(direct, md.Eclasses); err != nil {
+		log.Printf("Warning: %s: %v", path, err)
 	}
+	return md, nil
+}
 
-	// Регулярные выражения для парсинга
-	versionRe := regexp.MustCompile(`(?m)^VERSION="([^"]+)"`)
-	slotRe := regexp.MustCompile(`(?m)^SLOT="([^"]+)"`)
-	dependRe := regexp.MustCompile(`(?m)^RDEPEND="([^"]+)"`)
-	iuseRe := regexp.MustCompile(`(?m)^IUSE="([^"]+)"`)
-	provideRe := regexp.MustCompile(`(?m)^PROVIDE="([^"]+)"`)
+// NewPackageFromMetadata создает пакет из метаданных ebuild (md5-cache или генератора).
+// Версия с неизвестным EAPI или некорректными метаданными отклоняется.
+func NewPackageFromMetadata(name, version string, md *Metadata) (*pkg.Package, error) {
+	eapi, err := pkg.LookupEAPI(md.Get("EAPI"))
+	if err != nil {
+		return nil, fmt.Errorf("%s-%s: %w", name, version, err)
+	}
+	return newPackage(name, version, md, eapi, true)
+}
+
+// NewInstalledPackage создает пакет из записи базы установленных пакетов.
+// Установленная версия не отклоняется: при неизвестном EAPI метаданные
+// разбираются без проверок EAPI (Package.EAPI равен nil), а поля,
+// которые не удалось разобрать, пропускаются с предупреждением.
+func NewInstalledPackage(name, version string, md *Metadata) *pkg.Package {
+	eapi, err := pkg.LookupEAPI(md.Get("EAPI"))
+	if err != nil {
+		log.Printf("Warning: installed %s-%s: %v; reading metadata without EAPI checks", name, version, err)
+		eapi = nil
+	}
+	p, _ := newPackage(name, version, md, eapi, false)
+	return p
+}
 
-	// Извлекаем версию из имени файла
-	filename := filepath.Base(path)
-	if matches := strings.Split(filename, "-"); len(matches) > 1 {
-		p.Version = strings.TrimSuffix(matches[len(matches)-1], ".ebuild")
+// newPackage разбирает метаданные версии пакета. В строгом режиме первая ошибка
+// разбора возвращается, иначе поле с ошибкой пропускается с предупреждением.
+// eapi равен nil, если EAPI неизвестен: тогда возможности EAPI не проверяются.
+func newPackage(name, version string, md *Metadata, eapi *pkg.EAPI, strict bool) (*pkg.Package, error) {
+	// invalid возвращает ошибку разбора в строгом режиме и nil после предупреждения - иначе
+	invalid := func(err error) error {
+		if strict {
+			return fmt.Errorf("%w: %w", ErrInvalidMetadata, err)
+		}
+		log.Printf("Warning: ignoring %v", err)
+		return nil
 	}
 
-	// Парсим метаданные
-	if matches := versionRe.FindStringSubmatch(string(content)); len(matches) > 1 {
-		p.Version = matches[1]
+	p := pkg.NewPackage(name, version, "0")
+	p.EAPI = eapi
+
+	if slot := strings.TrimSpace(md.Get("SLOT")); slot != "" {
+		p.Slot = pkg.ParseSlot(slot)
 	}
 
-	// Парсим зависимости
-	if matches := dependRe.FindStringSubmatch(string(content)); len(matches) > 1 {
-		deps := parseDependencies(matches[1])
-		p.Deps = append(p.Deps, deps...)
-		log.Printf("Parsed dependencies for %s: %v", name, deps)
+	// Парсим зависимости всех классов; BDEPEND и IDEPEND не входят в метаданные ранних EAPI
+	classes := []struct {
+		key     string
+		allowed bool
+		spec    **pkg.DepSpec
+	}{
+		{"DEPEND", true, &p.Depend},
+		{"BDEPEND", eapi == nil || eapi.BDepend, &p.BDepend},
+		{"RDEPEND", true, &p.RDepend},
+		{"PDEPEND", true, &p.PDepend},
+		{"IDEPEND", eapi == nil || eapi.IDepend, &p.IDepend},
+	}
+	for _, class := range classes {
+		value := md.Get(class.key)
+		if value == "" || !class.allowed {
+			continue
+		}
+		spec, err := pkg.ParseDepSpec(value, eapi)
+		if err != nil {
+			if err := invalid(fmt.Errorf("invalid %s in %s-%s: %w", class.key, name, version, err)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		*class.spec = spec
+		log.Printf("Parsed %s for %s: %s", class.key, name, spec)
+	}
+	if p.RDepend != nil {
+		p.Deps = append(p.Deps, p.RDepend.Constraints()...)
 	}
 
-	if matches := slotRe.FindStringSubmatch(string(content)); len(matches) > 1 {
-		p.Slot = pkg.ParseSlot(matches[1])
+	if requiredUse := md.Get("REQUIRED_USE"); requiredUse != "" {
+		spec, err := pkg.ParseRequiredUse(requiredUse, eapi)
+		if err == nil {
+			p.RequiredUse = spec
+		} else if err := invalid(fmt.Errorf("invalid REQUIRED_USE in %s-%s: %w", name, version, err)); err != nil {
+			return nil, err
+		}
 	}
 
-	if matches := iuseRe.FindStringSubmatch(string(content)); len(matches) > 1 {
-		flags := strings.Fields(matches[1])
-		for _, flag := range flags {
-			flag = strings.TrimPrefix(flag, "+")
-			flag = strings.TrimPrefix(flag, "-")
-			p.UseFlags[flag] = true
+	if license := md.Get("LICENSE"); license != "" {
+		spec, err := pkg.ParseLicense(license)
+		if err == nil {
+			p.License = spec
+		} else if err := invalid(fmt.Errorf("invalid LICENSE in %s-%s: %w", name, version, err)); err != nil {
+			return nil, err
 		}
 	}
 
-	if matches := provideRe.FindStringSubmatch(string(content)); len(matches) > 1 {
-		provides := strings.Fields(matches[1])
-		for _, prov := range provides {
-			p.Provides = append(p.Provides, pkg.Constraint{
-				Type: pkg.ConstraintTypeVersion,
-				Name: prov,
-			})
+	// Флаги с префиксом "+" включены по умолчанию, остальные выключены
+	p.IUse = strings.Fields(md.Get("IUSE"))
+	for _, flag := range p.IUse {
+		if eapi != nil && !eapi.IUseDefaults && strings.ContainsAny(flag[:1], "+-") {
+			err := fmt.Errorf("invalid IUSE in %s-%s: defaults are not allowed in EAPI %s", name, version, eapi.Name)
+			if err := invalid(err); err != nil {
+				return nil, err
+			}
 		}
+		p.UseFlags[strings.TrimLeft(flag, "+-")] = strings.HasPrefix(flag, "+")
+	}
+
+	p.Keywords = strings.Fields(md.Get("KEYWORDS"))
+
+	for name, sum := range md.Eclasses {
+		p.Eclasses[name] = sum
+	}
+
+	for _, prov := range strings.Fields(md.Get("PROVIDE")) {
+		p.Provides = append(p.Provides, pkg.Constraint{
+			Type: pkg.ConstraintTypeVersion,
+			Name: prov,
+		})
 	}
 
 	return p, nil
@@ -169,59 +473,3 @@ func (pr *PortageRepository) findEbuildFiles(pkgDir string) ([]string, error) {
 
 	return ebuildFiles, err
 }
-
-// Улучшенный парсер зависимостей
-func parseDependencies(depString string) []pkg.Constraint {
-	log.Printf("Parsing dependencies: %s", depString)
-
-	var deps []pkg.Constraint
-
-	// Регулярка для извлечения зависимостей с операторами
-	re := regexp.MustCompile(`(!)?([^! ]+)(?:([<>]=?|=)([^ ]+))?`)
-	matches := re.FindAllStringSubmatch(depString, -1)
-
-	for _, m := range matches {
-		if len(m) < 3 {
-			continue
-		}
-
-		name := m[2]
-		if name == "" {
-			continue
-		}
-
-		// Обработка оператора
-		if len(m) > 4 && m[3] != "" && m[4] != "" {
-			op := m[3]
-			version := m[4]
-
-			var versionConstraint *pkg.VersionConstraint
-			switch op {
-			case ">=":
-				versionConstraint = pkg.NewVersionConstraint(pkg.OpGreaterEqual, version)
-			case "<=":
-				versionConstraint = pkg.NewVersionConstraint(pkg.OpLessEqual, version)
-			case ">":
-				versionConstraint = pkg.NewVersionConstraint(pkg.OpGreater, version)
-			case "<":
-				versionConstraint = pkg.NewVersionConstraint(pkg.OpLess, version)
-			case "=":
-				versionConstraint = pkg.NewVersionConstraint(pkg.OpEqual, version)
-			}
-
-			deps = append(deps, pkg.Constraint{
-				Type:    pkg.ConstraintTypeVersion,
-				Name:    name,
-				Version: versionConstraint,
-			})
-		} else {
-			// Зависимость без оператора
-			deps = append(deps, pkg.Constraint{
-				Type: pkg.ConstraintTypeVersion,
-				Name: name,
-			})
-		}
-	}
-
-	return deps
-}
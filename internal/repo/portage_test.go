@@ -0,0 +1,67 @@
+package repo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseEAPI(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"EAPI=8\ninherit foo\n", "8"},
+		{"EAPI=\"7\"\n", "7"},
+		{"EAPI='6'\n", "6"},
+		{"# Copyright\n\n  # comment\nEAPI=8 # trailing comment\n", "8"},
+		{"\tEAPI=5-progress\n", "5-progress"},
+		{"", "0"},
+		{"inherit foo\nEAPI=8\n", "0"},
+		{"DESCRIPTION=\"x\"\nEAPI=8\n", "0"},
+	}
+	for _, tt := range tests {
+		if got := parseEAPI([]byte(tt.content)); got != tt.want {
+			t.Errorf("parseEAPI(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+// Ebuild с некорректными метаданными пропускается, остальные версии пакета загружаются
+func TestLoadVersionsSkipsInvalidEbuild(t *testing.T) {
+	root := t.TempDir()
+	for path, content := range map[string]string{
+		"profiles/repo_name":               "test\n",
+		"app-misc/hello/hello-2.10.ebuild": "EAPI=8\nSLOT=\"0\"\nRDEPEND=\"dev-libs/foo\"\n",
+		"app-misc/hello/hello-2.11.ebuild": "EAPI=8\nSLOT=\"0\"\nDEPEND=\"|| ( dev-libs/foo\"\n",
+		"app-misc/hello/hello-2.12.ebuild": "EAPI=8\nSLOT=\"0\"\nLICENSE=\"|| (\"\n",
+		"app-misc/bad/bad-1.ebuild":        "EAPI=3\nSLOT=\"0\"\nREQUIRED_USE=\"ssl\"\n",
+	} {
+		full := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pr, err := NewPortageRepository(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := pr.LoadVersions("app-misc/hello")
+	if err != nil {
+		t.Fatalf("LoadVersions(app-misc/hello) error: %v", err)
+	}
+	if len(versions) != 1 || versions[0].Version != "2.10" {
+		t.Errorf("LoadVersions(app-misc/hello) = %v, want only 2.10", versions)
+	}
+
+	_, err = pr.LoadVersions("app-misc/bad")
+	if !errors.Is(err, ErrPackageNotFound) || !strings.Contains(err.Error(), "REQUIRED_USE") {
+		t.Errorf("LoadVersions(app-misc/bad) error = %v, want ErrPackageNotFound with the REQUIRED_USE error", err)
+	}
+}
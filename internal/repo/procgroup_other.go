@@ -0,0 +1,8 @@
+//go:build !unix
+
+package repo
+
+import "os/exec"
+
+// setProcessGroup: без групп процессов при отмене завершается только сам процесс
+func setProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package repo
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup запускает процесс в новой группе, чтобы при отмене завершить и его потомков
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
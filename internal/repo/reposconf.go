@@ -0,0 +1,98 @@
+package repo
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kolkov/gportage/internal/config"
+)
+
+// RepoConfig - секция repos.conf, описывающая один репозиторий
+type RepoConfig struct {
+	Name     string
+	Location string
+	Priority int
+	Masters  []string // nil - мастера не заданы в repos.conf
+	AutoSync bool
+	SyncType string
+	SyncURI  string
+}
+
+// ReposConf - содержимое /etc/portage/repos.conf
+type ReposConf struct {
+	MainRepo string        // main-repo из секции DEFAULT
+	Repos    []*RepoConfig // По убыванию приоритета, при равном приоритете - по имени
+}
+
+// LoadReposConf читает repos.conf (файл или каталог); отсутствующий путь дает пустую конфигурацию
+func LoadReposConf(path string) (*ReposConf, error) {
+	sections, err := config.ReadSections(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rc := &ReposConf{}
+	for _, s := range sections {
+		if s.Name == "DEFAULT" {
+			rc.MainRepo = s.Values["main-repo"]
+			continue
+		}
+
+		r := &RepoConfig{
+			Name:     s.Name,
+			Location: s.Values["location"],
+			AutoSync: true,
+			SyncType: s.Values["sync-type"],
+			SyncURI:  s.Values["sync-uri"],
+		}
+		if r.Location == "" {
+			log.Printf("Warning: %s: repository %s has no location, skipping", s.Source, s.Name)
+			continue
+		}
+		if priority := s.Values["priority"]; priority != "" {
+			if r.Priority, err = strconv.Atoi(priority); err != nil {
+				return nil, fmt.Errorf("%s: invalid priority %q for repository %s", s.Source, priority, s.Name)
+			}
+		}
+		if masters, ok := s.Values["masters"]; ok {
+			r.Masters = strings.Fields(masters)
+		}
+		switch strings.ToLower(s.Values["auto-sync"]) {
+		case "", "yes", "true":
+		case "no", "false":
+			r.AutoSync = false
+		default:
+			return nil, fmt.Errorf("%s: invalid auto-sync value %q for repository %s", s.Source, s.Values["auto-sync"], s.Name)
+		}
+		rc.Repos = append(rc.Repos, r)
+	}
+
+	sort.SliceStable(rc.Repos, func(i, j int) bool {
+		if rc.Repos[i].Priority != rc.Repos[j].Priority {
+			return rc.Repos[i].Priority > rc.Repos[j].Priority
+		}
+		return rc.Repos[i].Name < rc.Repos[j].Name
+	})
+	return rc, nil
+}
+
+// Get возвращает описание репозитория по имени
+func (rc *ReposConf) Get(name string) *RepoConfig {
+	for _, r := range rc.Repos {
+		if r.Name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// Main возвращает основной репозиторий: main-repo, а без него - репозиторий gentoo
+func (rc *ReposConf) Main() *RepoConfig {
+	if rc.MainRepo != "" {
+		return rc.Get(rc.MainRepo)
+	}
+	return rc.Get("gentoo")
+}
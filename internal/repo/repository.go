@@ -1,10 +1,17 @@
 package repo
 
 import (
+	"errors"
+
 	"github.com/kolkov/gportage/internal/pkg"
 )
 
+// ErrPackageNotFound возвращается LoadVersions, если в репозитории нет версий пакета
+var ErrPackageNotFound = errors.New("package not found")
+
 type Repository interface {
 	LoadPackages(names []string) ([]*pkg.Package, error)
 	LoadPackage(name string) (*pkg.Package, error)
+	// LoadVersions возвращает все версии пакета, отсортированные по убыванию
+	LoadVersions(name string) ([]*pkg.Package, error)
 }
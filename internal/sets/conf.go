@@ -0,0 +1,217 @@
+package sets
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kolkov/gportage/internal/config"
+	"github.com/kolkov/gportage/internal/vdb"
+)
+
+// PackageLister перечисляет пакеты репозитория в виде category/package
+type PackageLister interface {
+	ListPackages() ([]string, error)
+}
+
+// Sources - источники пакетов для наборов, описанных в sets.conf
+type Sources struct {
+	DB   *vdb.DB
+	Repo PackageLister // nil, если репозиторий недоступен
+}
+
+// LoadDir регистрирует статические наборы из каталога /etc/portage/sets.
+// Имя набора - путь файла относительно каталога.
+func (s *Sets) LoadDir(dir string) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+	files, err := config.ListConfigFiles(dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		name, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		s.Add(filepath.ToSlash(name), &FileSet{Path: file})
+	}
+	return nil
+}
+
+// LoadConfig регистрирует наборы из секций sets.conf (файл или каталог)
+func (s *Sets) LoadConfig(path string, src Sources) error {
+	sections, err := config.ReadSections(path)
+	if err != nil {
+		return err
+	}
+	for _, section := range sections {
+		set, err := newConfigSet(section, src)
+		if err != nil {
+			log.Printf("Warning: %s: set @%s: %v", section.Source, section.Name, err)
+			continue
+		}
+		s.Add(section.Name, set)
+	}
+	return nil
+}
+
+// newConfigSet создает набор по классу секции sets.conf.
+// Поддерживаются классы Portage и их короткие имена.
+func newConfigSet(section *config.Section, src Sources) (Set, error) {
+	values := section.Values
+	class := values["class"]
+	if i := strings.LastIndex(class, "."); i >= 0 {
+		class = class[i+1:]
+	}
+
+	switch class {
+	case "StaticFileSet":
+		if values["filename"] == "" {
+			return nil, fmt.Errorf("StaticFileSet requires filename")
+		}
+		return &FileSet{Path: values["filename"]}, nil
+
+	case "CategorySet":
+		if values["category"] == "" {
+			return nil, fmt.Errorf("CategorySet requires category")
+		}
+		set := &CategorySet{Category: values["category"], Repo: src.Repo}
+		switch values["repository"] {
+		case "", "porttree":
+		case "vartree":
+			set.Repo = &installedLister{db: src.DB}
+		default:
+			return nil, fmt.Errorf("unknown repository %q", values["repository"])
+		}
+		return set, nil
+
+	case "OwnerSet":
+		files := strings.Fields(values["files"])
+		if len(files) == 0 {
+			return nil, fmt.Errorf("OwnerSet requires files")
+		}
+		return &OwnerSet{DB: src.DB, Files: files}, nil
+
+	case "AgeSet":
+		set := &AgeSet{DB: src.DB, Age: 7, Older: true}
+		if age := values["age"]; age != "" {
+			days, err := strconv.Atoi(age)
+			if err != nil || days < 0 {
+				return nil, fmt.Errorf("invalid age %q", age)
+			}
+			set.Age = days
+		}
+		switch values["mode"] {
+		case "", "older":
+		case "newer":
+			set.Older = false
+		default:
+			return nil, fmt.Errorf("invalid mode %q", values["mode"])
+		}
+		return set, nil
+	}
+	return nil, fmt.Errorf("unsupported set class %q", values["class"])
+}
+
+// CategorySet содержит все пакеты категории
+type CategorySet struct {
+	Category string
+	Repo     PackageLister
+}
+
+func (s *CategorySet) Entries() ([]string, error) {
+	if s.Repo == nil {
+		return nil, fmt.Errorf("no repository to list category %s", s.Category)
+	}
+	names, err := s.Repo.ListPackages()
+	if err != nil {
+		return nil, err
+	}
+	var atoms []string
+	for _, name := range names {
+		if strings.HasPrefix(name, s.Category+"/") {
+			atoms = append(atoms, name)
+		}
+	}
+	return atoms, nil
+}
+
+// installedLister перечисляет установленные пакеты для CategorySet с repository = vartree
+type installedLister struct {
+	db *vdb.DB
+}
+
+func (l *installedLister) ListPackages() ([]string, error) {
+	return (&InstalledSet{DB: l.db}).Entries()
+}
+
+// OwnerSet содержит установленные пакеты, которым принадлежат указанные файлы
+type OwnerSet struct {
+	DB    *vdb.DB
+	Files []string
+}
+
+func (s *OwnerSet) Entries() ([]string, error) {
+	wanted := make(map[string]bool)
+	for _, f := range s.Files {
+		wanted[filepath.Clean(f)] = true
+	}
+
+	packages, err := s.DB.Installed()
+	if err != nil {
+		return nil, err
+	}
+	var atoms []string
+	for _, p := range packages {
+		contents, err := s.DB.Contents(p)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, c := range contents {
+			if wanted[c.Path] {
+				atoms = append(atoms, fmt.Sprintf("%s:%s", p.Name, p.Slot.Name))
+				break
+			}
+		}
+	}
+	return atoms, nil
+}
+
+// AgeSet содержит установленные пакеты, собранные раньше (Older) или позже Age дней назад
+type AgeSet struct {
+	DB    *vdb.DB
+	Age   int
+	Older bool
+}
+
+func (s *AgeSet) Entries() ([]string, error) {
+	cutoff := time.Now().AddDate(0, 0, -s.Age)
+
+	packages, err := s.DB.Installed()
+	if err != nil {
+		return nil, err
+	}
+	var atoms []string
+	for _, p := range packages {
+		built, err := s.DB.BuildTime(p)
+		if err != nil {
+			return nil, err
+		}
+		if built.IsZero() {
+			continue
+		}
+		if built.Before(cutoff) == s.Older {
+			atoms = append(atoms, fmt.Sprintf("=%s-%s", p.Name, p.Version))
+		}
+	}
+	return atoms, nil
+}
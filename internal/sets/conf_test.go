@@ -0,0 +1,116 @@
+package sets
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeFile записывает файл, создавая родительские каталоги
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fakeLister - репозиторий с фиксированным списком пакетов
+type fakeLister []string
+
+func (l fakeLister) ListPackages() ([]string, error) {
+	return l, nil
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "sets")
+	writeFile(t, filepath.Join(dir, "desktop"), "# Рабочий стол\nx11-wm/i3\n@tools\n")
+	writeFile(t, filepath.Join(dir, "tools"), "app-editors/vim\ndev-vcs/git\n")
+	writeFile(t, filepath.Join(dir, "work", "go"), "dev-lang/go\n")
+	writeFile(t, filepath.Join(dir, "tools~"), "app-editors/emacs\n")
+
+	s := New()
+	if err := s.LoadDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if names, want := s.Names(), []string{"desktop", "tools", "work/go"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("Names() = %v, want %v", names, want)
+	}
+
+	atoms, err := s.Expand([]string{"@desktop", "@work/go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"x11-wm/i3", "app-editors/vim", "dev-vcs/git", "dev-lang/go"}
+	if !reflect.DeepEqual(atoms, want) {
+		t.Errorf("Expand() = %v, want %v", atoms, want)
+	}
+
+	if err := New().LoadDir(filepath.Join(dir, "missing")); err != nil {
+		t.Errorf("LoadDir(missing) error: %v", err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "kde.list"), "kde-plasma/plasma-meta\n@qt\n")
+	writeFile(t, filepath.Join(root, "sets.conf"), `
+# Наборы пользователя
+[kde]
+class = portage.sets.files.StaticFileSet
+filename = `+filepath.Join(root, "kde.list")+`
+
+[qt]
+class = portage.sets.dbapi.CategorySet
+category = dev-qt
+
+[short]
+class = CategorySet
+category = kde-plasma
+
+[no-file]
+class = portage.sets.files.StaticFileSet
+
+[bad-age]
+class = portage.sets.dbapi.AgeSet
+age = soon
+
+[unknown]
+class = portage.sets.shell.CommandOutputSet
+command = echo app-misc/foo
+`)
+
+	s := New()
+	src := Sources{Repo: fakeLister{"dev-qt/qtbase", "dev-qt/qtsvg", "kde-plasma/plasma-meta", "app-misc/foo"}}
+	if err := s.LoadConfig(filepath.Join(root, "sets.conf"), src); err != nil {
+		t.Fatal(err)
+	}
+	if names, want := s.Names(), []string{"kde", "qt", "short"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("Names() = %v, want %v", names, want)
+	}
+
+	tests := []struct {
+		set   string
+		atoms []string
+	}{
+		{"kde", []string{"kde-plasma/plasma-meta", "dev-qt/qtbase", "dev-qt/qtsvg"}},
+		{"short", []string{"kde-plasma/plasma-meta"}},
+	}
+	for _, tt := range tests {
+		atoms, err := s.Expand([]string{"@" + tt.set})
+		if err != nil {
+			t.Errorf("Expand(@%s) error: %v", tt.set, err)
+			continue
+		}
+		if !reflect.DeepEqual(atoms, tt.atoms) {
+			t.Errorf("Expand(@%s) = %v, want %v", tt.set, atoms, tt.atoms)
+		}
+	}
+
+	if err := s.LoadConfig(filepath.Join(root, "missing.conf"), src); err != nil {
+		t.Errorf("LoadConfig(missing) error: %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package sets
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kolkov/gportage/internal/config"
+	"github.com/kolkov/gportage/internal/vdb"
+)
+
+// DefaultWorldDir - каталог файлов world и world_sets
+const DefaultWorldDir = "/var/lib/portage"
+
+// Set - именованный набор пакетов. Элементы - атомы или ссылки на другие наборы (@name).
+type Set interface {
+	Entries() ([]string, error)
+}
+
+// StaticSet - набор с фиксированным списком элементов
+type StaticSet []string
+
+func (s StaticSet) Entries() ([]string, error) {
+	return s, nil
+}
+
+// FileSet читает элементы набора из файла, по одному в строке; отсутствующий файл дает пустой набор
+type FileSet struct {
+	Path string
+}
+
+func (s *FileSet) Entries() ([]string, error) {
+	entries, err := config.ReadEntries(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	atoms := make([]string, 0, len(entries))
+	for _, e := range entries {
+		atoms = append(atoms, e.Fields[0])
+	}
+	return atoms, nil
+}
+
+// InstalledSet содержит все установленные пакеты
+type InstalledSet struct {
+	DB *vdb.DB
+}
+
+func (s *InstalledSet) Entries() ([]string, error) {
+	packages, err := s.DB.Installed()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	seen := make(map[string]bool)
+	for _, p := range packages {
+		if !seen[p.Name] {
+			seen[p.Name] = true
+			names = append(names, p.Name)
+		}
+	}
+	return names, nil
+}
+
+// Sets - реестр наборов пакетов
+type Sets struct {
+	sets map[string]Set
+}
+
+func New() *Sets {
+	return &Sets{sets: make(map[string]Set)}
+}
+
+// NewStandard создает реестр стандартных наборов:
+// @selected-packages и @selected-sets из world и world_sets, @selected,
+// @system из профиля, @installed из базы установленных пакетов и @world
+func NewStandard(worldDir string, system []string, db *vdb.DB) *Sets {
+	s := New()
+	s.Add("selected-packages", &FileSet{Path: filepath.Join(worldDir, "world")})
+	s.Add("selected-sets", &FileSet{Path: filepath.Join(worldDir, "world_sets")})
+	s.Add("selected", StaticSet{"@selected-packages", "@selected-sets"})
+	s.Add("system", StaticSet(system))
+	s.Add("installed", &InstalledSet{DB: db})
+	s.Add("world", StaticSet{"@selected", "@system"})
+	return s
+}
+
+// Add регистрирует набор, заменяя набор с тем же именем
+func (s *Sets) Add(name string, set Set) {
+	s.sets[name] = set
+}
+
+// Get возвращает набор по имени
+func (s *Sets) Get(name string) (Set, bool) {
+	set, ok := s.sets[name]
+	return set, ok
+}
+
+// Names возвращает имена зарегистрированных наборов по алфавиту
+func (s *Sets) Names() []string {
+	names := make([]string, 0, len(s.sets))
+	for name := range s.sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Expand заменяет аргументы вида @name атомами наборов, раскрывая вложенные наборы.
+// Порядок аргументов сохраняется, повторяющиеся атомы пропускаются.
+func (s *Sets) Expand(args []string) ([]string, error) {
+	var atoms []string
+	seen := make(map[string]bool)
+	for _, arg := range args {
+		if err := s.expand(arg, nil, seen, &atoms); err != nil {
+			return nil, err
+		}
+	}
+	return atoms, nil
+}
+
+// expand раскрывает один элемент; stack содержит раскрываемые наборы для обнаружения циклов
+func (s *Sets) expand(arg string, stack []string, seen map[string]bool, atoms *[]string) error {
+	name, ok := strings.CutPrefix(arg, "@")
+	if !ok {
+		if !seen[arg] {
+			seen[arg] = true
+			*atoms = append(*atoms, arg)
+		}
+		return nil
+	}
+
+	for i, parent := range stack {
+		if parent == name {
+			return fmt.Errorf("set @%s includes itself: @%s", name, strings.Join(append(stack[i:], name), " -> @"))
+		}
+	}
+
+	set, ok := s.sets[name]
+	if !ok {
+		return fmt.Errorf("unknown package set @%s", name)
+	}
+	entries, err := set.Entries()
+	if err != nil {
+		return fmt.Errorf("failed to read set @%s: %w", name, err)
+	}
+	for _, e := range entries {
+		if err := s.expand(e, append(stack, name), seen, atoms); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package sets
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	s := New()
+	s.Add("base", StaticSet{"app-misc/a", "app-misc/b"})
+	s.Add("extra", StaticSet{"@base", "app-misc/c", "app-misc/a"})
+	s.Add("all", StaticSet{"@extra", "@base", "app-misc/d"})
+	s.Add("self", StaticSet{"app-misc/a", "@self"})
+	s.Add("loop-a", StaticSet{"@loop-b"})
+	s.Add("loop-b", StaticSet{"@loop-c"})
+	s.Add("loop-c", StaticSet{"@loop-a"})
+	s.Add("broken", StaticSet{"@missing"})
+
+	tests := []struct {
+		args  []string
+		atoms []string
+		err   string // Подстрока ожидаемой ошибки
+	}{
+		{args: []string{"app-misc/x"}, atoms: []string{"app-misc/x"}},
+		{args: []string{"@base"}, atoms: []string{"app-misc/a", "app-misc/b"}},
+		{args: []string{"@extra"}, atoms: []string{"app-misc/a", "app-misc/b", "app-misc/c"}},
+		{args: []string{"app-misc/d", "@all"}, atoms: []string{"app-misc/d", "app-misc/a", "app-misc/b", "app-misc/c"}},
+		{args: []string{"@base", "@base"}, atoms: []string{"app-misc/a", "app-misc/b"}},
+		{args: []string{"@self"}, err: "set @self includes itself: @self -> @self"},
+		{args: []string{"@loop-a"}, err: "set @loop-a includes itself: @loop-a -> @loop-b -> @loop-c -> @loop-a"},
+		{args: []string{"@nothing"}, err: "unknown package set @nothing"},
+		{args: []string{"@broken"}, err: "unknown package set @missing"},
+	}
+	for _, tt := range tests {
+		atoms, err := s.Expand(tt.args)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Expand(%v) error = %v, want %q", tt.args, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Expand(%v) error: %v", tt.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(atoms, tt.atoms) {
+			t.Errorf("Expand(%v) = %v, want %v", tt.args, atoms, tt.atoms)
+		}
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"log"
 	"sort"
-	"strings"
 
 	"github.com/crillab/gophersat/solver"
 	"github.com/kolkov/gportage/internal/pkg"
@@ -12,19 +11,127 @@ import (
 
 type GophersatAdapter struct {
 	clauses      [][]int
-	vars         map[string]int            // name@version -> var ID
-	varNames     map[int]string            // var ID -> name@version
+	vars         map[string]int            // Ключ переменной -> var ID
+	varNames     map[int]string            // var ID -> ключ переменной
+	pkgVars      map[int]*pkg.Package      // var ID -> версия пакета
 	packages     map[string][]*pkg.Package // name -> []versions
 	addedClauses map[string]struct{}       // для предотвращения дублирования
+	useVars      map[int]useFlagVar        // var ID -> USE-флаг версии пакета
+	useChanges   *UseChanges               // nil: USE-флаги фиксированы
+	objectives   []*Objective              // Критерии оптимизации в порядке убывания приоритета
+	model        []bool                    // Модель последнего успешного решения
+}
+
+// Objective - минимизируемая сумма весов истинных литералов.
+// Критерии оптимизируются лексикографически: следующий не может ухудшить предыдущие.
+type Objective struct {
+	lits    []int
+	weights []int
+}
+
+// Add добавляет вес weight за истинность литерала lit
+func (o *Objective) Add(lit, weight int) {
+	o.lits = append(o.lits, lit)
+	o.weights = append(o.weights, weight)
+}
+
+// useFlagVar связывает переменную решателя с USE-флагом версии пакета
+type useFlagVar struct {
+	pkg  *pkg.Package
+	flag string
+}
+
+// UseChanges разрешает решателю менять USE-флаги со штрафом Weight за каждый измененный флаг.
+// Fixed сообщает о флагах, которые менять нельзя (например, из use.force и use.mask).
+type UseChanges struct {
+	Weight int
+	Fixed  func(p *pkg.Package, flag string) bool
 }
 
 func NewGophersatAdapter() *GophersatAdapter {
 	return &GophersatAdapter{
 		vars:         make(map[string]int),
 		varNames:     make(map[int]string),
+		pkgVars:      make(map[int]*pkg.Package),
 		packages:     make(map[string][]*pkg.Package),
 		addedClauses: make(map[string]struct{}),
+		useVars:      make(map[int]useFlagVar),
+		objectives:   []*Objective{{}},
+	}
+}
+
+// SetUseChanges разрешает изменение USE-флагов; должен вызываться до добавления ограничений
+func (g *GophersatAdapter) SetUseChanges(c *UseChanges) {
+	g.useChanges = c
+}
+
+// AddPenalty добавляет в основной критерий (изменения конфигурации) вес weight за истинность литерала lit
+func (g *GophersatAdapter) AddPenalty(lit, weight int) {
+	g.objectives[0].Add(lit, weight)
+}
+
+// AddObjective добавляет критерий с приоритетом ниже всех добавленных ранее
+func (g *GophersatAdapter) AddObjective() *Objective {
+	o := &Objective{}
+	g.objectives = append(g.objectives, o)
+	return o
+}
+
+// PackageVar возвращает переменную выбора версии пакета.
+// Копии одной версии из разных репозиториев - разные переменные.
+// Решение строится только из таких переменных, поэтому ключи USE-флагов
+// и вспомогательных переменных не влияют на него.
+func (g *GophersatAdapter) PackageVar(p *pkg.Package) int {
+	id := g.getVarID(p.Name + "@" + p.Version + "::" + p.Repository)
+	if _, exists := g.pkgVars[id]; !exists {
+		g.pkgVars[id] = p
+	}
+	return id
+}
+
+// Penalize штрафует выбор версии пакета (например, скрытой маской)
+func (g *GophersatAdapter) Penalize(p *pkg.Package, weight int) {
+	g.AddPenalty(g.PackageVar(p), weight)
+}
+
+// Selected возвращает версии пакетов, выбранные в последнем решении, упорядоченные
+// по имени и слоту. Пакет может быть выбран одновременно в нескольких слотах.
+func (g *GophersatAdapter) Selected() []*pkg.Package {
+	var selected []*pkg.Package
+	for id, p := range g.pkgVars {
+		if g.isTrue(id) {
+			selected = append(selected, p)
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		if selected[i].Name != selected[j].Name {
+			return selected[i].Name < selected[j].Name
+		}
+		return selected[i].Slot.Name < selected[j].Slot.Name
+	})
+	return selected
+}
+
+// isTrue проверяет значение переменной в модели последнего решения
+func (g *GophersatAdapter) isTrue(id int) bool {
+	return id <= len(g.model) && g.model[id-1]
+}
+
+// ChangedUse возвращает USE-флаги выбранных версий, значения которых решатель изменил
+func (g *GophersatAdapter) ChangedUse() map[*pkg.Package]map[string]bool {
+	changed := make(map[*pkg.Package]map[string]bool)
+	for id, v := range g.useVars {
+		if !g.isTrue(g.PackageVar(v.pkg)) || id > len(g.model) {
+			continue
+		}
+		if state := g.model[id-1]; state != v.pkg.UseFlags[v.flag] {
+			if changed[v.pkg] == nil {
+				changed[v.pkg] = make(map[string]bool)
+			}
+			changed[v.pkg][v.flag] = state
+		}
 	}
+	return changed
 }
 
 func (g *GophersatAdapter) getVarID(key string) int {
@@ -39,6 +146,11 @@ func (g *GophersatAdapter) getVarID(key string) int {
 	return id
 }
 
+// newAuxVar создает вспомогательную переменную для кодирования групп зависимостей
+func (g *GophersatAdapter) newAuxVar() int {
+	return g.getVarID(fmt.Sprintf("_aux:%d", len(g.vars)+1))
+}
+
 func (g *GophersatAdapter) addClause(clause []int) {
 	// Создаем уникальный ключ для клаузы (упорядоченный)
 	sortedClause := make([]int, len(clause))
@@ -57,24 +169,25 @@ func (g *GophersatAdapter) addClause(clause []int) {
 }
 
 func (g *GophersatAdapter) AddPackage(p *pkg.Package) {
-	key := p.Name + "@" + p.Version
-
 	// Регистрируем пакет
 	if _, exists := g.packages[p.Name]; !exists {
 		g.packages[p.Name] = []*pkg.Package{}
 	}
 
-	// Убедимся, что эта версия еще не добавлена
+	// Убедимся, что эта версия из этого репозитория еще не добавлена
 	for _, existing := range g.packages[p.Name] {
-		if existing.Version == p.Version {
+		if existing.Version == p.Version && existing.Repository == p.Repository {
 			return
 		}
 	}
 
 	g.packages[p.Name] = append(g.packages[p.Name], p)
 
+	// Версии храним по убыванию, чтобы кандидаты перебирались от новых к старым
+	pkg.SortByVersion(g.packages[p.Name])
+
 	// Регистрируем переменную
-	g.getVarID(key)
+	g.PackageVar(p)
 
 	// Логирование
 	log.Printf("Added package: %s-%s", p.Name, p.Version)
@@ -96,17 +209,33 @@ func (g *GophersatAdapter) AddConstraint(c pkg.Constraint) error {
 func (g *GophersatAdapter) addVersionConstraint(c pkg.Constraint) error {
 	log.Printf("Processing constraint: %s %s", c.Name, c.Version)
 
+	// Блокировки запрещают все подходящие версии
+	if c.Atom != nil && c.Atom.Blocker != pkg.BlockerNone {
+		return g.addBlockerConstraint(c)
+	}
+
 	// Для простых ограничений без версии
-	if c.Version == nil {
+	if c.Atom == nil && c.Version == nil && c.Slot == "" {
 		return g.addSimpleConstraint(c.Name)
 	}
 
+	// Атом с USE-зависимостями требует версию с подходящими флагами
+	if c.Atom != nil && len(c.Atom.UseDeps) > 0 {
+		lits := g.candidateLits(nil, c)
+		if len(lits) == 0 {
+			log.Printf("Warning: no package satisfies %s", c)
+			return nil
+		}
+		g.addClause(lits)
+		return nil
+	}
+
 	// Собираем все пакеты, удовлетворяющие ограничению
 	var satisfiedVars []int
 	for _, p := range g.packages[c.Name] {
-		if c.Version.Satisfies(p.Version) {
+		if matchConstraint(c, p) {
 			key := p.Name + "@" + p.Version
-			varID := g.getVarID(key)
+			varID := g.PackageVar(p)
 			satisfiedVars = append(satisfiedVars, varID)
 			log.Printf("Package %s satisfies constraint %s %s", key, c.Name, c.Version.String())
 		} else {
@@ -125,14 +254,214 @@ func (g *GophersatAdapter) addVersionConstraint(c pkg.Constraint) error {
 	return nil
 }
 
+// matchConstraint проверяет версию пакета по ограничению, используя атом, если он есть
+func matchConstraint(c pkg.Constraint, p *pkg.Package) bool {
+	if c.Atom != nil {
+		return c.Atom.Match(p)
+	}
+	return c.Version.Satisfies(p.Version)
+}
+
+func (g *GophersatAdapter) addBlockerConstraint(c pkg.Constraint) error {
+	for _, p := range g.packages[c.Name] {
+		if c.Atom.Match(p) {
+			varID := g.PackageVar(p)
+			g.addClause([]int{-varID})
+			log.Printf("Package %s@%s is blocked by %s", p.Name, p.Version, c.Atom)
+		}
+	}
+	return nil
+}
+
+// AddDependencies кодирует деревья зависимостей specs пакета как импликации:
+// выбор пакета требует выполнения его зависимостей с учетом USE-флагов пакета
+func (g *GophersatAdapter) AddDependencies(p *pkg.Package, specs []*pkg.DepSpec) {
+	parent := g.PackageVar(p)
+
+	if !p.HasDepSpecs() {
+		// Пакеты без деревьев зависимостей описываются плоским списком ограничений
+		for _, dep := range p.Deps {
+			g.requireConstraint(p, parent, dep)
+		}
+		return
+	}
+
+	for _, spec := range specs {
+		g.requireSpec(p, parent, spec)
+	}
+}
+
+// requireSpec добавляет клаузы parent -> spec для зависимостей пакета p
+func (g *GophersatAdapter) requireSpec(p *pkg.Package, parent int, spec *pkg.DepSpec) {
+	switch spec.Kind {
+	case pkg.DepAtom:
+		g.requireConstraint(p, parent, spec.Atom.Constraint())
+
+	case pkg.DepAllOf:
+		for _, c := range spec.Children {
+			g.requireSpec(p, parent, c)
+		}
+
+	case pkg.DepUseConditional:
+		if g.useChanges != nil {
+			// Флаги могут измениться: ветвь требуется при выборе пакета и выполнении условия
+			guard := g.andLit([]int{parent, g.useCondLit(p, spec)})
+			for _, c := range spec.Children {
+				g.requireSpec(p, guard, c)
+			}
+			return
+		}
+		if spec.ConditionMet(p.UseFlags) {
+			for _, c := range spec.Children {
+				g.requireSpec(p, parent, c)
+			}
+		}
+
+	case pkg.DepAnyOf:
+		clause := []int{-parent}
+		alternatives := 0
+		var unmet []int // Литералы "условие альтернативы не выполнено" при изменяемых USE-флагах
+		for _, c := range spec.Children {
+			if c.Kind == pkg.DepUseConditional && g.useChanges != nil {
+				// Альтернатива действует, только если выполнено ее USE-условие
+				cond := g.useCondLit(p, c)
+				aux := g.newAuxVar()
+				for _, cc := range c.Children {
+					g.requireSpec(p, aux, cc)
+				}
+				clause = append(clause, g.andLit([]int{cond, aux}))
+				unmet = append(unmet, -cond)
+				continue
+			}
+			if c.Kind == pkg.DepUseConditional && !c.ConditionMet(p.UseFlags) {
+				continue
+			}
+			alternatives++
+			if c.Kind == pkg.DepAtom && c.Atom.Blocker == pkg.BlockerNone {
+				// Кандидаты простого атома входят в дизъюнкцию напрямую
+				clause = append(clause, g.candidateLits(p, c.Atom.Constraint())...)
+				continue
+			}
+			// Составная альтернатива представляется вспомогательной переменной
+			aux := g.newAuxVar()
+			g.requireSpec(p, aux, c)
+			clause = append(clause, aux)
+		}
+		// Группа || ( ) без применимых альтернатив считается выполненной
+		if alternatives == 0 {
+			if len(unmet) == 0 {
+				return
+			}
+			clause = append(clause, g.andLit(unmet))
+		}
+		g.addClause(clause)
+	}
+}
+
+// requireConstraint добавляет клаузы parent -> constraint для зависимости пакета p
+func (g *GophersatAdapter) requireConstraint(p *pkg.Package, parent int, c pkg.Constraint) {
+	candidates := g.candidateLits(p, c)
+
+	if c.Atom != nil && c.Atom.Blocker != pkg.BlockerNone {
+		for _, lit := range candidates {
+			g.addClause([]int{-parent, -lit})
+		}
+		return
+	}
+
+	if len(candidates) == 0 {
+		log.Printf("Warning: no package satisfies %s required by %s", c, g.varNames[parent])
+	}
+	g.addClause(append([]int{-parent}, candidates...))
+}
+
+// candidateVars возвращает переменные всех версий, подходящих под ограничение
+func (g *GophersatAdapter) candidateVars(c pkg.Constraint) []int {
+	var vars []int
+	for _, p := range g.packages[c.Name] {
+		if matchConstraint(c, p) {
+			vars = append(vars, g.PackageVar(p))
+		}
+	}
+	return vars
+}
+
+// candidateLits возвращает литералы кандидатов ограничения, которого требует пакет parent.
+// Для атома с USE-зависимостями литерал кандидата означает "версия выбрана
+// и ее USE-флаги соответствуют требованиям атома".
+func (g *GophersatAdapter) candidateLits(parent *pkg.Package, c pkg.Constraint) []int {
+	if c.Atom == nil || len(c.Atom.UseDeps) == 0 {
+		return g.candidateVars(c)
+	}
+
+	var lits []int
+	for _, child := range g.packages[c.Name] {
+		if !matchConstraint(c, child) {
+			continue
+		}
+		conj := []int{g.PackageVar(child)}
+		for _, dep := range c.Atom.UseDeps {
+			conj = append(conj, g.useDepLit(parent, child, dep))
+		}
+		lits = append(lits, g.andLit(conj))
+	}
+	return lits
+}
+
+// useDepLit кодирует USE-зависимость (PMS, раздел 8.3.4) как связь между
+// USE-переменными родительского пакета и зависимости.
+// Условные формы без родительского пакета (например, в аргументах команды) не действуют.
+func (g *GophersatAdapter) useDepLit(parent, child *pkg.Package, dep pkg.UseDep) int {
+	var cf int
+	if _, ok := child.UseFlags[dep.Flag]; ok {
+		cf = g.useVar(child, dep.Flag)
+	} else {
+		// Флага нет в IUSE зависимости: действует значение по умолчанию (+)/(-)
+		switch dep.Default {
+		case pkg.UseDefaultEnabled:
+			cf = g.trueVar()
+		case pkg.UseDefaultDisabled:
+			cf = -g.trueVar()
+		default:
+			log.Printf("Warning: %s-%s has no USE flag %s required by %s", child.Name, child.Version, dep.Flag, dep)
+			return -g.trueVar()
+		}
+	}
+
+	if parent == nil {
+		switch dep.Type {
+		case pkg.UseDepEnabled:
+			return cf
+		case pkg.UseDepDisabled:
+			return -cf
+		default:
+			return g.trueVar()
+		}
+	}
+
+	pf := g.useVar(parent, dep.Flag)
+	switch dep.Type {
+	case pkg.UseDepEnabled:
+		return cf
+	case pkg.UseDepDisabled:
+		return -cf
+	case pkg.UseDepEqual:
+		return g.andLit([]int{g.orLit([]int{-pf, cf}), g.orLit([]int{pf, -cf})})
+	case pkg.UseDepNotEqual:
+		return g.andLit([]int{g.orLit([]int{-pf, -cf}), g.orLit([]int{pf, cf})})
+	case pkg.UseDepIfEnabled:
+		return g.orLit([]int{-pf, cf})
+	default: // UseDepIfDisabled
+		return g.orLit([]int{pf, -cf})
+	}
+}
+
 func (g *GophersatAdapter) addSimpleConstraint(name string) error {
 	// Исправлено: проверка существования пакета
 	if versions, exists := g.packages[name]; exists && len(versions) > 0 {
 		var packageVars []int
 		for _, p := range versions {
-			key := p.Name + "@" + p.Version
-			varID := g.getVarID(key)
-			packageVars = append(packageVars, varID)
+			packageVars = append(packageVars, g.PackageVar(p))
 		}
 		g.addClause(packageVars)
 		return nil
@@ -143,10 +472,7 @@ func (g *GophersatAdapter) addSimpleConstraint(name string) error {
 func (g *GophersatAdapter) AddExactlyOneConstraint(pkgName string, versions []string) {
 	var versionVars []int
 	for _, version := range versions {
-		key := pkgName + "@" + version
-		if varID, exists := g.vars[key]; exists {
-			versionVars = append(versionVars, varID)
-		}
+		versionVars = append(versionVars, g.versionVars(pkgName, version)...)
 	}
 
 	if len(versionVars) == 0 {
@@ -167,14 +493,41 @@ func (g *GophersatAdapter) AddExactlyOneConstraint(pkgName string, versions []st
 	log.Printf("Added exactly-one constraint for %s: %d versions", pkgName, len(versions))
 }
 
+// versionVars возвращает переменные всех копий версии пакета из разных репозиториев
+func (g *GophersatAdapter) versionVars(pkgName, version string) []int {
+	var vars []int
+	for _, p := range g.packages[pkgName] {
+		if p.Version == version {
+			vars = append(vars, g.PackageVar(p))
+		}
+	}
+	return vars
+}
+
+// AddAtMostOneConstraint запрещает одновременный выбор нескольких версий из списка
+func (g *GophersatAdapter) AddAtMostOneConstraint(pkgName string, versions []string) {
+	var versionVars []int
+	for _, version := range versions {
+		versionVars = append(versionVars, g.versionVars(pkgName, version)...)
+	}
+
+	for i := 0; i < len(versionVars); i++ {
+		for j := i + 1; j < len(versionVars); j++ {
+			g.addClause([]int{-versionVars[i], -versionVars[j]})
+		}
+	}
+	if len(versionVars) > 1 {
+		log.Printf("Added at-most-one constraint for %s: %d versions", pkgName, len(versionVars))
+	}
+}
+
 func (g *GophersatAdapter) addSlotConstraint(c pkg.Constraint) error {
 	// Находим все пакеты с указанным слотом
 	var slotVars []int
 	for _, pkgList := range g.packages {
 		for _, p := range pkgList {
 			if p.Slot.Name == c.Slot {
-				key := p.Name + "@" + p.Version
-				varID := g.vars[key]
+				varID := g.PackageVar(p)
 				slotVars = append(slotVars, varID)
 			}
 		}
@@ -189,15 +542,163 @@ func (g *GophersatAdapter) addSlotConstraint(c pkg.Constraint) error {
 	return nil
 }
 
+// addUseFlagConstraint требует, чтобы выбранная версия пакета c.Name собиралась с флагом c.Flag
 func (g *GophersatAdapter) addUseFlagConstraint(c pkg.Constraint) error {
-	if c.Required {
-		// Создаем переменную для USE-флага
-		flagVar := g.getVarID("USE_" + c.Flag)
-		g.addClause([]int{flagVar})
+	if c.Name == "" {
+		return fmt.Errorf("USE flag constraint %s has no package", c.Flag)
+	}
+	if !c.Required {
+		return nil
+	}
+	for _, p := range g.packages[c.Name] {
+		parent := g.PackageVar(p)
+		g.addClause([]int{-parent, g.useVar(p, c.Flag)})
 	}
 	return nil
 }
 
+// useVar возвращает переменную USE-флага конкретной версии пакета
+func (g *GophersatAdapter) useVar(p *pkg.Package, flag string) int {
+	key := fmt.Sprintf("USE:%d:%s", g.PackageVar(p), flag)
+	if id, exists := g.vars[key]; exists {
+		return id
+	}
+
+	id := g.getVarID(key)
+	g.useVars[id] = useFlagVar{pkg: p, flag: flag}
+
+	lit := id
+	if !p.UseFlags[flag] {
+		lit = -id
+	}
+
+	// Флаг из IUSE можно изменить со штрафом, остальные фиксируются эффективным USE пакета
+	_, inIUse := p.UseFlags[flag]
+	if g.useChanges != nil && inIUse && (g.useChanges.Fixed == nil || !g.useChanges.Fixed(p, flag)) {
+		g.AddPenalty(-lit, g.useChanges.Weight)
+		return id
+	}
+	g.addClause([]int{lit})
+	return id
+}
+
+// useCondLit возвращает литерал USE-условия flag? или !flag? для пакета p
+func (g *GophersatAdapter) useCondLit(p *pkg.Package, spec *pkg.DepSpec) int {
+	lit := g.useVar(p, spec.Flag)
+	if spec.Negate {
+		return -lit
+	}
+	return lit
+}
+
+// trueVar возвращает переменную, всегда равную true
+func (g *GophersatAdapter) trueVar() int {
+	if id, exists := g.vars["_true"]; exists {
+		return id
+	}
+	id := g.getVarID("_true")
+	g.addClause([]int{id})
+	return id
+}
+
+// AddRequiredUse кодирует REQUIRED_USE пакета: выбор версии требует выполнения ограничений
+// над ее USE-флагами
+func (g *GophersatAdapter) AddRequiredUse(p *pkg.Package) {
+	if p.RequiredUse == nil {
+		return
+	}
+	parent := g.PackageVar(p)
+	g.addClause([]int{-parent, g.requiredUseLit(p, p.RequiredUse)})
+}
+
+// requiredUseLit возвращает литерал, эквивалентный выполнению узла REQUIRED_USE
+func (g *GophersatAdapter) requiredUseLit(p *pkg.Package, spec *pkg.DepSpec) int {
+	if spec.Kind == pkg.DepUseFlag {
+		lit := g.useVar(p, spec.Flag)
+		if spec.Negate {
+			return -lit
+		}
+		return lit
+	}
+
+	lits := make([]int, 0, len(spec.Children))
+	for _, c := range spec.Children {
+		lits = append(lits, g.requiredUseLit(p, c))
+	}
+
+	switch spec.Kind {
+	case pkg.DepUseConditional:
+		return g.orLit([]int{-g.useCondLit(p, spec), g.andLit(lits)})
+	case pkg.DepAnyOf:
+		if len(lits) == 0 {
+			return g.emptyGroupLit(p)
+		}
+		return g.orLit(lits)
+	case pkg.DepExactlyOneOf:
+		if len(lits) == 0 {
+			return g.emptyGroupLit(p)
+		}
+		return g.andLit([]int{g.orLit(lits), g.atMostOneLit(lits)})
+	case pkg.DepAtMostOneOf:
+		return g.atMostOneLit(lits)
+	default:
+		return g.andLit(lits)
+	}
+}
+
+// emptyGroupLit возвращает значение пустой группы || ( ) или ^^ ( ) в EAPI пакета
+func (g *GophersatAdapter) emptyGroupLit(p *pkg.Package) int {
+	if pkg.EmptyGroupTrue(p.EAPI) {
+		return g.trueVar()
+	}
+	return -g.trueVar()
+}
+
+// andLit возвращает вспомогательную переменную, эквивалентную конъюнкции литералов
+func (g *GophersatAdapter) andLit(lits []int) int {
+	switch len(lits) {
+	case 0:
+		return g.trueVar()
+	case 1:
+		return lits[0]
+	}
+	aux := g.newAuxVar()
+	back := []int{aux}
+	for _, l := range lits {
+		g.addClause([]int{-aux, l})
+		back = append(back, -l)
+	}
+	g.addClause(back)
+	return aux
+}
+
+// orLit возвращает вспомогательную переменную, эквивалентную дизъюнкции литералов
+func (g *GophersatAdapter) orLit(lits []int) int {
+	switch len(lits) {
+	case 0:
+		return -g.trueVar()
+	case 1:
+		return lits[0]
+	}
+	aux := g.newAuxVar()
+	g.addClause(append([]int{-aux}, lits...))
+	for _, l := range lits {
+		g.addClause([]int{aux, -l})
+	}
+	return aux
+}
+
+// atMostOneLit возвращает литерал "истинно не более одного литерала"
+func (g *GophersatAdapter) atMostOneLit(lits []int) int {
+	var pairs []int
+	for i := 0; i < len(lits); i++ {
+		for j := i + 1; j < len(lits); j++ {
+			pairs = append(pairs, g.orLit([]int{-lits[i], -lits[j]}))
+		}
+	}
+	return g.andLit(pairs)
+}
+
 // exactlyOne генерирует клаузы, гарантирующие, что ровно одна переменная из списка истинна
 func exactlyOne(vars []int) [][]int {
 	// Добавляем клаузу: хотя бы одна истинна
@@ -240,29 +741,26 @@ func (g *GophersatAdapter) Solve() (pkg.Status, map[string]string, error) {
 	   }
 	*/
 
-	// Создаем проблему
-	pb := solver.ParseSlice(g.clauses)
-
-	// Создаем решатель
-	s := solver.New(pb)
-	s.Verbose = false
-
-	// Решаем проблему
-	status := s.Solve()
+	var status solver.Status
+	var model []bool
+	if g.optimize() {
+		status, model = g.minimize()
+	} else {
+		s := solver.New(solver.ParseSliceNb(g.clauses, len(g.vars)))
+		s.Verbose = false
+		if status = s.Solve(); status == solver.Sat {
+			model = s.Model()
+		}
+	}
 
 	if status == solver.Sat {
 		log.Printf("SAT solution found")
 		solution := make(map[string]string)
-		model := s.Model()
-
-		// Проходим по всем зарегистрированным переменным
-		for key, varID := range g.vars {
-			if varID <= len(model) && model[varID-1] {
-				parts := strings.Split(key, "@")
-				if len(parts) == 2 {
-					solution[parts[0]] = parts[1]
-				}
-			}
+		g.model = model
+
+		// Решение составляют выбранные версии пакетов с ключами вида name:slot
+		for _, p := range g.Selected() {
+			solution[p.Name+":"+p.Slot.Name] = p.Version
 		}
 		return pkg.StatusSat, solution, nil
 	}
@@ -276,6 +774,73 @@ func (g *GophersatAdapter) Solve() (pkg.Status, map[string]string, error) {
 	return pkg.StatusIndet, nil, fmt.Errorf("solver timeout")
 }
 
+// optimize проверяет, заданы ли критерии оптимизации
+func (g *GophersatAdapter) optimize() bool {
+	for _, o := range g.objectives {
+		if len(o.lits) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// minimize последовательно минимизирует критерии; найденный оптимум каждого
+// критерия добавляется ограничением при оптимизации следующих
+func (g *GophersatAdapter) minimize() (solver.Status, []bool) {
+	constrs := make([]solver.PBConstr, 0, len(g.clauses)+len(g.objectives)+1)
+	for _, clause := range g.clauses {
+		constrs = append(constrs, solver.PropClause(clause...))
+	}
+	// Тривиальное ограничение задает число переменных задачи, включая не вошедшие в клаузы
+	if len(g.vars) > 0 {
+		constrs = append(constrs, solver.PBConstr{Lits: []int{len(g.vars)}, AtLeast: 0})
+	}
+
+	var model []bool
+	for i, o := range g.objectives {
+		if len(o.lits) == 0 {
+			continue
+		}
+		pb := solver.ParsePBConstrs(copyConstrs(constrs))
+		lits := make([]solver.Lit, len(o.lits))
+		for j, l := range o.lits {
+			lits[j] = solver.IntToLit(int32(l))
+		}
+		pb.SetCostFunc(lits, o.weights)
+
+		s := solver.New(pb)
+		s.Verbose = false
+		cost := s.Minimize()
+		if cost < 0 {
+			return solver.Unsat, nil
+		}
+		log.Printf("Optimal cost of objective %d: %d", i, cost)
+		model = s.Model()
+
+		// LtEq изменяет переданные срезы
+		bound := append([]int(nil), o.lits...)
+		weights := append([]int(nil), o.weights...)
+		constrs = append(constrs, solver.LtEq(bound, weights, cost))
+	}
+	return solver.Sat, model
+}
+
+// copyConstrs возвращает глубокую копию ограничений: ParsePBConstrs изменяет
+// литералы и веса на месте, а ограничения используются при каждой оптимизации
+func copyConstrs(constrs []solver.PBConstr) []solver.PBConstr {
+	copied := make([]solver.PBConstr, len(constrs))
+	for i, c := range constrs {
+		copied[i] = solver.PBConstr{
+			Lits:    append([]int(nil), c.Lits...),
+			AtLeast: c.AtLeast,
+		}
+		if c.Weights != nil {
+			copied[i].Weights = append([]int(nil), c.Weights...)
+		}
+	}
+	return copied
+}
+
 // Новый метод для получения версий пакета
 func (g *GophersatAdapter) GetPackageVersions(name string) []string {
 	var versions []string
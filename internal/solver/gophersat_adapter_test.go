@@ -0,0 +1,178 @@
+package solver
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kolkov/gportage/internal/pkg"
+)
+
+// testPackage создает версию пакета с RDEPEND и флагами IUSE (флаги с "+" включены)
+func testPackage(t *testing.T, name, version, rdepend string, iuse ...string) *pkg.Package {
+	t.Helper()
+	p := pkg.NewPackage(name, version, "0")
+	if rdepend != "" {
+		spec, err := pkg.ParseDepSpec(rdepend, nil)
+		if err != nil {
+			t.Fatalf("ParseDepSpec(%q): %v", rdepend, err)
+		}
+		p.RDepend = spec
+	}
+	p.IUse = iuse
+	for _, flag := range iuse {
+		p.UseFlags[strings.TrimLeft(flag, "+-")] = strings.HasPrefix(flag, "+")
+	}
+	return p
+}
+
+// solveAdapter добавляет версии и зависимости в решатель и требует установки target
+func solveAdapter(t *testing.T, g *GophersatAdapter, target string, packages ...*pkg.Package) map[string]string {
+	t.Helper()
+	for _, p := range packages {
+		g.AddPackage(p)
+	}
+	for _, p := range packages {
+		g.AddDependencies(p, []*pkg.DepSpec{p.RDepend})
+	}
+	atom, err := pkg.ParseAtom(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.AddConstraint(atom.Constraint()); err != nil {
+		t.Fatal(err)
+	}
+	status, solution, err := g.Solve()
+	if err != nil || status != pkg.StatusSat {
+		t.Fatalf("Solve() = %v, %v", status, err)
+	}
+	return solution
+}
+
+func TestSolveIgnoresUseFlagVars(t *testing.T) {
+	app := testPackage(t, "app-misc/app", "1.0", "dev-libs/lib[x@y]")
+	lib := testPackage(t, "dev-libs/lib", "1.0", "", "+x@y")
+
+	solution := solveAdapter(t, NewGophersatAdapter(), "app-misc/app", app, lib)
+	want := map[string]string{"app-misc/app:0": "1.0", "dev-libs/lib:0": "1.0"}
+	if len(solution) != len(want) {
+		t.Errorf("solution = %v, want %v", solution, want)
+	}
+	for name, version := range want {
+		if solution[name] != version {
+			t.Errorf("solution[%s] = %q, want %q", name, solution[name], version)
+		}
+	}
+}
+
+// Кодирование REQUIRED_USE в решателе совпадает с проверкой Satisfied
+// для всех наборов фиксированных флагов, в том числе для пустых групп до EAPI 8 и в нем
+func TestRequiredUseEncoding(t *testing.T) {
+	flags := []string{"a", "b", "c"}
+	specs := []string{
+		"a",
+		"!a b",
+		"|| ( a b c )",
+		"^^ ( a b c )",
+		"?? ( a b c )",
+		"a? ( b ) !a? ( c )",
+		"^^ ( a ( b c ) )",
+		"|| ( ^^ ( a b ) c ) ?? ( a !c )",
+		"|| ( )",
+		"^^ ( ) ?? ( )",
+		"a? ( || ( ) )",
+		"|| ( b ^^ ( ) )",
+	}
+	var eapis []*pkg.EAPI
+	for _, name := range []string{"7", "8"} {
+		eapi, err := pkg.LookupEAPI(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		eapis = append(eapis, eapi)
+	}
+	for _, s := range specs {
+		spec, err := pkg.ParseRequiredUse(s, nil)
+		if err != nil {
+			t.Fatalf("ParseRequiredUse(%q): %v", s, err)
+		}
+		for _, eapi := range eapis {
+			for mask := 0; mask < 1<<len(flags); mask++ {
+				var iuse []string
+				use := make(map[string]bool)
+				for i, flag := range flags {
+					if mask&(1<<i) != 0 {
+						iuse = append(iuse, "+"+flag)
+						use[flag] = true
+					} else {
+						iuse = append(iuse, flag)
+					}
+				}
+				p := testPackage(t, "app-misc/p", "1", "", iuse...)
+				p.RequiredUse = spec
+				p.EAPI = eapi
+
+				g := NewGophersatAdapter()
+				g.AddPackage(p)
+				g.AddRequiredUse(p)
+				if err := g.AddConstraint(pkg.Constraint{Type: pkg.ConstraintTypeVersion, Name: p.Name}); err != nil {
+					t.Fatal(err)
+				}
+				status, _, err := g.Solve()
+				if err != nil {
+					t.Fatalf("Solve() error: %v", err)
+				}
+				if want := spec.Satisfied(use, eapi); (status == pkg.StatusSat) != want {
+					t.Errorf("REQUIRED_USE %q with USE %v in EAPI %s: status %v, want satisfiable = %v", s, use, eapi.Name, status, want)
+				}
+			}
+		}
+	}
+}
+
+// Кодирование USE-зависимостей совпадает с проверкой MatchUse
+// при фиксированных флагах родителя и зависимости
+func TestUseDepEncoding(t *testing.T) {
+	deps := []string{"x", "-x", "x=", "!x=", "x?", "!x?", "y(+)", "y(-)", "-y(-)", "y", "x,-z"}
+	for _, dep := range deps {
+		for mask := 0; mask < 8; mask++ {
+			parentX, childX, childZ := mask&1 != 0, mask&2 != 0, mask&4 != 0
+			flag := func(name string, on bool) string {
+				if on {
+					return "+" + name
+				}
+				return name
+			}
+			app := testPackage(t, "app-misc/app", "1", "dev-libs/lib["+dep+"]", flag("x", parentX))
+			lib := testPackage(t, "dev-libs/lib", "1", "", flag("x", childX), flag("z", childZ))
+			atom := app.RDepend.Atoms()[0]
+
+			g := NewGophersatAdapter()
+			g.AddPackage(app)
+			g.AddPackage(lib)
+			g.AddDependencies(app, []*pkg.DepSpec{app.RDepend})
+			if err := g.AddConstraint(pkg.Constraint{Type: pkg.ConstraintTypeVersion, Name: app.Name}); err != nil {
+				t.Fatal(err)
+			}
+			status, _, err := g.Solve()
+			if err != nil {
+				t.Fatalf("Solve() error: %v", err)
+			}
+			if want := atom.MatchUse(lib, app.UseFlags); (status == pkg.StatusSat) != want {
+				t.Errorf("[%s] with parent USE %v, child USE %v: status %v, want satisfiable = %v",
+					dep, app.UseFlags, lib.UseFlags, status, want)
+			}
+		}
+	}
+}
+
+// Без autounmask выбирается версия, флаги которой соответствуют USE-зависимости
+func TestUseDepSelectsMatchingVersion(t *testing.T) {
+	app := testPackage(t, "app-misc/app", "1", "dev-libs/lib[ssl]")
+	lib1 := testPackage(t, "dev-libs/lib", "1", "", "+ssl")
+	lib2 := testPackage(t, "dev-libs/lib", "2", "", "ssl")
+
+	solution := solveAdapter(t, NewGophersatAdapter(), "app-misc/app", app, lib1, lib2)
+	if solution["dev-libs/lib:0"] != "1" {
+		t.Errorf("dev-libs/lib = %q, want 1 with ssl enabled", solution["dev-libs/lib:0"])
+	}
+}
@@ -1,129 +1,506 @@
 package solver
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"sort"
+	"strings"
 
 	"github.com/kolkov/gportage/internal/pkg"
 	"github.com/kolkov/gportage/internal/repo"
+	"github.com/kolkov/gportage/internal/useflags"
+	"github.com/kolkov/gportage/internal/visibility"
+)
+
+// Веса изменений конфигурации при autounmask: изменения USE предпочтительнее
+// ключевых слов, а снятие маски - самое нежелательное
+const (
+	autounmaskUseWeight     = 1
+	autounmaskKeywordWeight = 10
+	autounmaskLicenseWeight = 10
+	autounmaskMaskWeight    = 100
 )
 
 type PortageResolver struct {
-	repo repo.Repository
+	repo       repo.Repository
+	installed  repo.Repository // База установленных пакетов; nil - установленных пакетов нет
+	visibility *visibility.Filter
+	use        *useflags.Calculator
+	autounmask bool
+	binary     bool                                 // Пакеты устанавливаются из бинарных пакетов без сборки
+	relaxed    map[*pkg.Package][]visibility.Reason // Скрытые версии, допущенные к решению при autounmask
+	changes    []visibility.Change                  // Изменения конфигурации, предложенные autounmask
+	hidden     map[string][]visibility.Hidden       // Скрытые версии, найденные при последнем разрешении
+	unmetUse   map[string][]string                  // Невыполненные REQUIRED_USE и USE-зависимости версий графа
+	current    map[string][]*pkg.Package            // Установленные версии пакетов графа
+	vdbOnly    map[*pkg.Package]bool                // Установленные версии, ebuild которых нет в репозитории
+	order      []string                             // Пакеты графа в порядке обнаружения
 }
 
 func NewResolver(r repo.Repository) *PortageResolver {
 	return &PortageResolver{repo: r}
 }
 
-func (r *PortageResolver) collectDependencies(pkg *pkg.Package, allPackages map[string]*pkg.Package) error {
-	if _, exists := allPackages[pkg.Name]; exists {
-		return nil // Уже обработан
+// SetVisibility задает фильтр, скрывающий версии до передачи кандидатов решателю
+func (r *PortageResolver) SetVisibility(f *visibility.Filter) {
+	r.visibility = f
+}
+
+// SetInstalled задает базу установленных пакетов: решатель сохраняет установленные версии,
+// если обновление не запрошено и не требуется зависимостями
+func (r *PortageResolver) SetInstalled(installed repo.Repository) {
+	r.installed = installed
+}
+
+// SetBinary задает установку из бинарных пакетов: зависимости сборки
+// (DEPEND и BDEPEND) при этом не требуются
+func (r *PortageResolver) SetBinary(on bool) {
+	r.binary = on
+}
+
+// depSpecs возвращает деревья зависимостей, которые должны выполняться при выборе версии:
+// RDEPEND и PDEPEND - всегда, IDEPEND - если версия устанавливается,
+// DEPEND и BDEPEND - если она собирается из исходников.
+// Уже установленная версия не переустанавливается и не собирается.
+func (r *PortageResolver) depSpecs(p *pkg.Package) []*pkg.DepSpec {
+	specs := []*pkg.DepSpec{p.RDepend, p.PDepend}
+	if !r.isInstalled(p) {
+		specs = append(specs, p.IDepend)
+		if !r.binary {
+			specs = append(specs, p.Depend, p.BDepend)
+		}
+	}
+
+	present := specs[:0]
+	for _, spec := range specs {
+		if spec != nil {
+			present = append(present, spec)
+		}
 	}
+	return present
+}
+
+// SetAutounmask разрешает решателю снимать ограничения видимости и менять USE-флаги
+// с минимальным суммарным штрафом; предложенные изменения возвращает Changes
+func (r *PortageResolver) SetAutounmask(on bool) {
+	r.autounmask = on
+}
 
-	// Сохраняем копию пакета
-	copyPkg := *pkg
-	allPackages[pkg.Name] = &copyPkg
+// Changes возвращает изменения конфигурации, необходимые для найденного решения
+func (r *PortageResolver) Changes() []visibility.Change {
+	return r.changes
+}
 
-	// Обрабатываем зависимости
-	for _, dep := range pkg.Deps {
-		// Загружаем зависимый пакет
-		depPkg, err := r.repo.LoadPackage(dep.Name)
-		if err != nil {
-			log.Printf("Warning: dependency %s for %s not found: %v", dep.Name, pkg.Name, err)
+// Installed возвращает установленные версии пакета из последнего разрешения
+func (r *PortageResolver) Installed(name string) []*pkg.Package {
+	return r.current[name]
+}
+
+// Action описывает операцию над выбранной версией относительно установленных:
+// N - новый пакет, NS - новый слот, R - переустановка, U - обновление, UD - откат
+func (r *PortageResolver) Action(p *pkg.Package) string {
+	installed := r.current[p.Name]
+	if len(installed) == 0 {
+		return "N"
+	}
+	for _, i := range installed {
+		if i.Slot.Name != p.Slot.Name {
 			continue
 		}
+		switch cmp := pkg.CompareVersions(p.Version, i.Version); {
+		case cmp == 0:
+			return "R"
+		case cmp > 0:
+			return "U"
+		default:
+			return "UD"
+		}
+	}
+	return "NS"
+}
 
-		// Рекурсивно обрабатываем его зависимости
-		if err := r.collectDependencies(depPkg, allPackages); err != nil {
-			log.Printf("Warning: %v", err)
+// isInstalled проверяет, установлена ли версия пакета
+func (r *PortageResolver) isInstalled(p *pkg.Package) bool {
+	for _, i := range r.current[p.Name] {
+		if sameOrigin(i, p) {
+			return true
 		}
 	}
+	return false
+}
+
+// sameOrigin проверяет, что версии совпадают и получены из одного репозитория.
+// Установленные версии без записи о репозитории совпадают с копией из любого.
+func sameOrigin(a, b *pkg.Package) bool {
+	if pkg.CompareVersions(a.Version, b.Version) != 0 {
+		return false
+	}
+	return a.Repository == "" || b.Repository == "" || a.Repository == b.Repository
+}
 
+// loadInstalled запоминает установленные версии пакета
+func (r *PortageResolver) loadInstalled(name string) error {
+	if r.installed == nil {
+		return nil
+	}
+	versions, err := r.installed.LoadVersions(name)
+	if errors.Is(err, repo.ErrPackageNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read installed versions of %s: %w", name, err)
+	}
+	r.current[name] = versions
 	return nil
 }
 
-func (r *PortageResolver) Resolve(packages []string) (map[string]*pkg.Package, error) {
-	adapter := NewGophersatAdapter()
-	allPackages := make(map[string]*pkg.Package)
+// relaxWeight возвращает штраф за снятие причин скрытия версии
+func relaxWeight(reasons []visibility.Reason) int {
+	weight := 0
+	for _, reason := range reasons {
+		switch reason.Kind {
+		case visibility.ReasonKeyword:
+			weight += autounmaskKeywordWeight
+		case visibility.ReasonLicense:
+			weight += autounmaskLicenseWeight
+		default:
+			weight += autounmaskMaskWeight
+		}
+	}
+	return weight
+}
 
-	// Загрузка и сбор всех зависимостей
-	for _, pkgName := range packages {
-		p, err := r.repo.LoadPackage(pkgName)
-		if err != nil {
-			return nil, fmt.Errorf("failed to load package %s: %w", pkgName, err)
+// relaxable проверяет, что все причины скрытия можно снять изменением конфигурации
+func relaxable(reasons []visibility.Reason) bool {
+	for _, reason := range reasons {
+		if reason.Change == nil {
+			return false
 		}
+	}
+	return true
+}
 
-		log.Printf("Resolving package: %s-%s with %d dependencies",
-			p.Name, p.Version, len(p.Deps))
+// SetUseCalculator задает вычисление эффективных USE-флагов для загружаемых пакетов
+func (r *PortageResolver) SetUseCalculator(c *useflags.Calculator) {
+	r.use = c
+}
 
-		if err := r.collectDependencies(p, allPackages); err != nil {
-			log.Printf("Warning: %v", err)
+// hiddenReport описывает скрытые версии пакетов для сообщения об ошибке.
+// Без аргументов перечисляются все скрытые версии графа зависимостей.
+func (r *PortageResolver) hiddenReport(names ...string) string {
+	if len(names) == 0 {
+		for name := range r.hidden {
+			names = append(names, name)
 		}
+		sort.Strings(names)
 	}
 
-	log.Printf("Total packages in dependency graph: %d", len(allPackages))
+	var sb strings.Builder
+	for _, name := range names {
+		for _, h := range r.hidden[name] {
+			for _, reason := range h.Reasons {
+				fmt.Fprintf(&sb, "\n  - %s-%s: %s", h.Package.Name, h.Package.Version, reason)
+			}
+		}
+	}
+	if sb.Len() == 0 {
+		return ""
+	}
+	return "\nThe following versions are not visible:" + sb.String()
+}
 
-	// Сначала добавляем ВСЕ пакеты в решатель
-	for _, p := range allPackages {
-		adapter.AddPackage(p)
+// requiredUseReport описывает версии с невыполненными ограничениями REQUIRED_USE и USE-зависимостями
+func (r *PortageResolver) requiredUseReport() string {
+	names := make([]string, 0, len(r.unmetUse))
+	for name := range r.unmetUse {
+		names = append(names, name)
 	}
+	sort.Strings(names)
 
-	// Затем добавляем ограничения
-	for _, p := range allPackages {
-		// Добавляем ограничение, что основной пакет должен быть установлен
-		if contains(packages, p.Name) {
-			versionStr := "any"
-			if p.Version != "" {
-				versionStr = p.Version
+	var sb strings.Builder
+	for _, name := range names {
+		for _, line := range r.unmetUse[name] {
+			fmt.Fprintf(&sb, "\n  - %s", line)
+		}
+	}
+	if sb.Len() == 0 {
+		return ""
+	}
+	return "\nThe following USE requirements are unsatisfied:" + sb.String()
+}
+
+// checkUseDeps запоминает зависимость, ни одна версия которой не собрана с требуемыми USE-флагами
+func (r *PortageResolver) checkUseDeps(p *pkg.Package, dep pkg.Constraint, candidates []*pkg.Package) {
+	if dep.Atom == nil || len(dep.Atom.UseDeps) == 0 {
+		return
+	}
+	matched := false
+	for _, c := range candidates {
+		if !dep.Atom.Match(c) {
+			continue
+		}
+		matched = true
+		if dep.Atom.MatchUse(c, p.UseFlags) {
+			return
+		}
+	}
+	if matched {
+		r.unmetUse[p.Name] = append(r.unmetUse[p.Name], fmt.Sprintf("%s-%s: %s (no version with matching USE)", p.Name, p.Version, dep.Atom))
+	}
+}
+
+// collectDependencies загружает все версии пакета и рекурсивно - версии его зависимостей
+func (r *PortageResolver) collectDependencies(name string, allPackages map[string][]*pkg.Package) error {
+	if _, exists := allPackages[name]; exists {
+		return nil // Уже обработан
+	}
+
+	// Отсутствующий пакет запоминается, чтобы не загружать его повторно.
+	// Пакет, которого нет в репозитории, может быть установлен.
+	versions, err := r.repo.LoadVersions(name)
+	if err != nil && !errors.Is(err, repo.ErrPackageNotFound) {
+		allPackages[name] = nil
+		return err
+	}
+	if err := r.loadInstalled(name); err != nil {
+		allPackages[name] = nil
+		return err
+	}
+	if len(versions) == 0 && len(r.current[name]) == 0 {
+		allPackages[name] = nil
+		return err
+	}
+
+	// USE-флаги вычисляются до проверки видимости: от них зависят условия в LICENSE
+	if r.use != nil {
+		for _, p := range versions {
+			r.use.Apply(p)
+		}
+	}
+
+	// Скрытые версии не передаются решателю, но запоминаются для отчета
+	loaded := versions
+	versions, hidden := r.visibility.Split(versions)
+	for _, h := range hidden {
+		log.Printf("Hiding %s-%s: %v", h.Package.Name, h.Package.Version, h.Reasons)
+	}
+	if len(hidden) > 0 {
+		r.hidden[name] = hidden
+	}
+	if r.autounmask {
+		// Скрытые версии становятся кандидатами со штрафом за изменение конфигурации
+		for _, h := range hidden {
+			if relaxable(h.Reasons) {
+				versions = append(versions, h.Package)
+				r.relaxed[h.Package] = h.Reasons
 			}
-			log.Printf("Adding constraint for required package: %s = %s", p.Name, versionStr)
+		}
+	}
 
-			adapter.AddConstraint(pkg.Constraint{
-				Type:    pkg.ConstraintTypeVersion,
-				Name:    p.Name,
-				Version: pkg.NewVersionConstraint(pkg.OpEqual, p.Version),
-			})
+	// Установленные версии, ebuild которых удален из репозитория, можно сохранить, но не пересобрать
+	for _, i := range r.current[name] {
+		if !slices.ContainsFunc(loaded, func(p *pkg.Package) bool { return sameOrigin(i, p) }) {
+			log.Printf("Keeping installed %s-%s without an ebuild as a candidate", i.Name, i.Version)
+			versions = append(versions, i)
+			r.vdbOnly[i] = true
 		}
+	}
+	pkg.SortByVersion(versions)
+	allPackages[name] = versions
+	if len(versions) == 0 {
+		return fmt.Errorf("all versions of %s are masked", name)
+	}
+	r.order = append(r.order, name)
 
-		// Добавляем зависимости пакета
-		for _, dep := range p.Deps {
-			// Проверяем существует ли пакет
-			if _, ok := allPackages[dep.Name]; !ok {
-				log.Printf("Skipping unresolved dependency: %s", dep.Name)
+	// Обрабатываем зависимости всех версий, отбрасывая ветви с невыполненными USE-условиями
+	for _, p := range versions {
+		// Версии с невыполненным REQUIRED_USE исключит решатель, причины сохраняются для отчета
+		for _, unmet := range p.RequiredUse.Unsatisfied(p.UseFlags, p.EAPI) {
+			log.Printf("Warning: %s-%s: REQUIRED_USE not satisfied: %s", p.Name, p.Version, unmet)
+			r.unmetUse[name] = append(r.unmetUse[name], fmt.Sprintf("%s-%s: %s", p.Name, p.Version, unmet))
+		}
+		deps := p.Deps
+		if p.HasDepSpecs() {
+			deps = nil
+			for _, spec := range r.depSpecs(p) {
+				if r.autounmask {
+					// USE-флаги могут измениться, поэтому загружаются зависимости всех ветвей
+					deps = append(deps, spec.Constraints()...)
+				} else {
+					deps = append(deps, spec.Evaluate(p.UseFlags).Constraints()...)
+				}
+			}
+		}
+		for _, dep := range deps {
+			// Блокировки не добавляют пакеты в граф
+			if dep.Atom != nil && dep.Atom.Blocker != pkg.BlockerNone {
 				continue
 			}
 
-			versionStr := "any"
-			if dep.Version != nil {
-				versionStr = dep.Version.String()
+			if err := r.collectDependencies(dep.Name, allPackages); err != nil {
+				log.Printf("Warning: dependency %s for %s-%s not found: %v", dep.Name, p.Name, p.Version, err)
+				continue
 			}
-			log.Printf("Adding dependency constraint: %s %s", dep.Name, versionStr)
+			r.checkUseDeps(p, dep, allPackages[dep.Name])
+		}
+	}
 
-			if err := adapter.AddConstraint(dep); err != nil {
-				log.Printf("Warning: failed to add constraint: %v", err)
+	return nil
+}
+
+// Resolve возвращает версии пакетов решения, упорядоченные по имени и слоту
+func (r *PortageResolver) Resolve(packages []string) ([]*pkg.Package, error) {
+	adapter := NewGophersatAdapter()
+	allPackages := make(map[string][]*pkg.Package)
+	r.hidden = make(map[string][]visibility.Hidden)
+	r.unmetUse = make(map[string][]string)
+	r.relaxed = make(map[*pkg.Package][]visibility.Reason)
+	r.current = make(map[string][]*pkg.Package)
+	r.vdbOnly = make(map[*pkg.Package]bool)
+	r.order = nil
+	r.changes = nil
+	if r.autounmask {
+		adapter.SetUseChanges(&UseChanges{Weight: autounmaskUseWeight, Fixed: r.fixedUse})
+	}
+
+	// Загрузка и сбор всех зависимостей
+	var targets []pkg.Constraint
+	var targetNames []string
+	requested := make(map[string]bool)
+	for _, arg := range packages {
+		atom, err := pkg.ParseAtom(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid package argument %s: %w", arg, err)
+		}
+
+		if err := r.collectDependencies(atom.Name(), allPackages); err != nil {
+			return nil, fmt.Errorf("failed to load package %s: %w%s", arg, err, r.hiddenReport(atom.Name()))
+		}
+		log.Printf("Resolving package: %s with %d candidate versions", arg, len(allPackages[atom.Name()]))
+		targets = append(targets, atom.Constraint())
+		if !requested[atom.Name()] {
+			targetNames = append(targetNames, atom.Name())
+		}
+		requested[atom.Name()] = true
+	}
+
+	// Обходим пакеты в фиксированном порядке, чтобы задача SAT была детерминированной
+	names := make([]string, 0, len(allPackages))
+	for name := range allPackages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	log.Printf("Total packages in dependency graph: %d", len(names))
+
+	// Сначала добавляем ВСЕ версии в решатель
+	for _, name := range names {
+		for _, p := range allPackages[name] {
+			adapter.AddPackage(p)
+		}
+	}
+
+	for p, reasons := range r.relaxed {
+		adapter.Penalize(p, relaxWeight(reasons))
+	}
+
+	// После изменений конфигурации критерии оптимизируются лексикографически:
+	// наибольшие версии запрошенных пакетов в порядке аргументов, минимум новых
+	// установок и замен установленных версий, наибольшие версии зависимостей
+	// в порядке обнаружения, порядок альтернатив
+	var targetVersions []lexTerm
+	for _, name := range targetNames {
+		targetVersions = append(targetVersions, preferHighest(adapter, allPackages[name]))
+	}
+	addLexicographic(adapter, targetVersions)
+
+	// Штраф назначается пакету, а не каждой его версии: сумма по версиям
+	// при ограничении "одна версия в слоте" превращает доказательство оптимума
+	// в задачу о голубях, и время решения растет экспоненциально с размером графа
+	replacements := adapter.AddObjective()
+	for _, name := range names {
+		if requested[name] {
+			continue
+		}
+		var fresh []int
+		for _, p := range allPackages[name] {
+			if !r.isInstalled(p) {
+				fresh = append(fresh, adapter.PackageVar(p))
 			}
 		}
+		if len(fresh) > 0 {
+			replacements.Add(adapter.orLit(fresh), 1)
+		}
+	}
+
+	var depVersions []lexTerm
+	for _, name := range r.order {
+		if !requested[name] {
+			depVersions = append(depVersions, preferHighest(adapter, allPackages[name]))
+		}
 	}
+	addLexicographic(adapter, depVersions)
 
-	// УБРАТЬ: дублирующий вызов AddExactlyOneConstraint
-	/*
-	   processedPackages := make(map[string]bool)
-	   for _, p := range allPackages {
-	       if !processedPackages[p.Name] {
-	           versions := adapter.GetPackageVersions(p.Name)
-	           if len(versions) > 0 {
-	               adapter.AddExactlyOneConstraint(p.Name, versions)
-	               processedPackages[p.Name] = true
-	           }
-	       }
-	   }
-	*/
+	// Из равноценных решений выбирается то, что обходится пакетами, найденными раньше:
+	// так первая альтернатива || ( ) предпочтительнее следующих.
+	// Сильнее всего штрафуется пакет, найденный последним.
+	var alternatives []lexTerm
+	for i := len(r.order) - 1; i >= 0; i-- {
+		if requested[r.order[i]] {
+			continue
+		}
+		var vars []int
+		for _, p := range allPackages[r.order[i]] {
+			vars = append(vars, adapter.PackageVar(p))
+		}
+		alternatives = append(alternatives, lexTerm{lits: []int{adapter.orLit(vars)}, weights: []int{1}, max: 1})
+	}
+	addLexicographic(adapter, alternatives)
+
+	// Затем добавляем ограничения
+	for _, name := range names {
+		versions := allPackages[name]
+
+		// В каждом слоте может быть установлена только одна версия
+		slots := make(map[string][]string)
+		var slotNames []string
+		for _, p := range versions {
+			if _, ok := slots[p.Slot.Name]; !ok {
+				slotNames = append(slotNames, p.Slot.Name)
+			}
+			if !slices.Contains(slots[p.Slot.Name], p.Version) {
+				slots[p.Slot.Name] = append(slots[p.Slot.Name], p.Version)
+			}
+		}
+		for _, slot := range slotNames {
+			adapter.AddAtMostOneConstraint(name, slots[slot])
+		}
+
+		// Добавляем зависимости каждой версии как импликации от ее выбора
+		for _, p := range versions {
+			log.Printf("Adding dependency constraints for %s-%s", p.Name, p.Version)
+			adapter.AddDependencies(p, r.depSpecs(p))
+			adapter.AddRequiredUse(p)
+		}
+	}
+
+	// Запрошенные пакеты должны быть установлены
+	for _, target := range targets {
+		if len(adapter.candidateVars(target)) == 0 {
+			return nil, fmt.Errorf("no version of %s matches %s%s", target.Name, target, r.hiddenReport(target.Name))
+		}
+		log.Printf("Adding constraint for required package: %s", target)
+		if err := adapter.AddConstraint(target); err != nil {
+			return nil, fmt.Errorf("failed to add constraint for %s: %w", target, err)
+		}
+	}
 
 	log.Printf("Total clauses in SAT problem: %d", len(adapter.clauses))
 
 	// Решение
-	status, solution, err := adapter.Solve()
+	status, _, err := adapter.Solve()
 	if err != nil {
 		return nil, err
 	}
@@ -133,34 +510,213 @@ func (r *PortageResolver) Resolve(packages []string) (map[string]*pkg.Package, e
 		for i, clause := range adapter.clauses {
 			log.Printf("Clause %d: %v", i, clause)
 		}
-		return nil, fmt.Errorf("no solution found")
+		return nil, fmt.Errorf("no solution found%s%s", r.hiddenReport(), r.requiredUseReport())
 	}
 
-	// Построение результата
-	result := make(map[string]*pkg.Package)
-	for name, _ := range solution {
-		p, err := r.repo.LoadPackage(name)
-		if err != nil {
-			log.Printf("Warning: package %s not found: %v", name, err)
-			continue
+	// Построение результата из выбранных версий
+	result := adapter.Selected()
+
+	if r.autounmask {
+		changedUse := adapter.ChangedUse()
+		r.changes = r.collectChanges(result, changedUse)
+
+		// Решение описывает пакеты с учетом предложенных изменений USE
+		for p, flags := range changedUse {
+			for flag, state := range flags {
+				p.UseFlags[flag] = state
+			}
 		}
-		result[name] = p
 	}
 
 	// Вывод красивого списка пакетов
 	log.Println("\nResolved packages:")
-	for name, p := range result {
-		log.Printf("- %s-%s [slot:%s]", name, p.Version, p.Slot.Name)
+	for _, p := range result {
+		log.Printf("- %s-%s [slot:%s]", p.Name, p.Version, p.Slot.Name)
 	}
 
 	return result, nil
 }
 
-func contains(slice []string, item string) bool {
-	for _, s := range slice {
-		if s == item {
-			return true
+// MergeOrder возвращает пакеты решения в порядке установки: зависимости сборки,
+// установки и выполнения ставятся раньше пакета, PDEPEND - после него.
+// Зависимость без слота требует всех выбранных слотов пакета.
+// Циклические зависимости разрываются в порядке обхода.
+func (r *PortageResolver) MergeOrder(solution []*pkg.Package) []*pkg.Package {
+	byName := make(map[string][]*pkg.Package)
+	for _, p := range solution {
+		byName[p.Name] = append(byName[p.Name], p)
+	}
+
+	var order []*pkg.Package
+	visited := make(map[*pkg.Package]bool)
+	var visit func(p *pkg.Package)
+	visitDeps := func(deps []pkg.Constraint) {
+		for _, dep := range deps {
+			for _, d := range byName[dep.Name] {
+				if matchConstraint(dep, d) {
+					visit(d)
+				}
+			}
 		}
 	}
-	return false
+	visit = func(p *pkg.Package) {
+		if visited[p] {
+			return
+		}
+		visited[p] = true
+
+		var before []*pkg.DepSpec
+		for _, spec := range r.depSpecs(p) {
+			if spec != p.PDepend {
+				before = append(before, spec)
+			}
+		}
+		visitDeps(specDeps(p, before))
+		order = append(order, p)
+		if p.PDepend != nil {
+			visitDeps(specDeps(p, []*pkg.DepSpec{p.PDepend}))
+		}
+	}
+	for _, p := range solution {
+		visit(p)
+	}
+	return order
+}
+
+// specDeps возвращает зависимости, требуемые деревьями specs при USE-флагах пакета, без блокировок
+func specDeps(p *pkg.Package, specs []*pkg.DepSpec) []pkg.Constraint {
+	if !p.HasDepSpecs() {
+		return p.Deps
+	}
+	var deps []pkg.Constraint
+	for _, spec := range specs {
+		for _, dep := range spec.Evaluate(p.UseFlags).Constraints() {
+			if dep.Atom == nil || dep.Atom.Blocker == pkg.BlockerNone {
+				deps = append(deps, dep)
+			}
+		}
+	}
+	return deps
+}
+
+// lexTerm - штрафы одного пакета в лексикографическом критерии;
+// max - наибольший штраф, который пакет может получить
+type lexTerm struct {
+	lits    []int
+	weights []int
+	max     int
+}
+
+// maxLexWeight ограничивает веса критерия, объединяющего штрафы нескольких пакетов
+const maxLexWeight = 1 << 30
+
+// addLexicographic добавляет критерии, минимизирующие штрафы пакетов лексикографически
+// в порядке terms. Соседние пакеты объединяются в один критерий: вес пакета превышает
+// наибольший суммарный штраф всех следующих за ним в критерии, поэтому ни одно их
+// улучшение не окупает ухудшения для него. Когда веса превысили бы maxLexWeight,
+// начинается следующий критерий с меньшим приоритетом.
+func addLexicographic(adapter *GophersatAdapter, terms []lexTerm) {
+	// Веса назначаются с конца: младший пакет критерия получает вес 1
+	var groups [][]lexTerm
+	var scales [][]int
+	var group []lexTerm
+	var scale []int
+	bound := 0 // Наибольший суммарный штраф пакетов группы с учетом весов
+	for i := len(terms) - 1; i >= 0; i-- {
+		t := terms[i]
+		if t.max == 0 {
+			continue
+		}
+		if len(group) > 0 && int64(bound+1)*int64(t.max)+int64(bound) > maxLexWeight {
+			groups = append(groups, group)
+			scales = append(scales, scale)
+			group, scale, bound = nil, nil, 0
+		}
+		w := bound + 1
+		group = append(group, t)
+		scale = append(scale, w)
+		bound += w * t.max
+	}
+	if len(group) > 0 {
+		groups = append(groups, group)
+		scales = append(scales, scale)
+	}
+
+	// Группы собраны от младших к старшим и добавляются в порядке приоритета
+	for i := len(groups) - 1; i >= 0; i-- {
+		o := adapter.AddObjective()
+		for j, t := range groups[i] {
+			for k, lit := range t.lits {
+				o.Add(lit, t.weights[k]*scales[i][j])
+			}
+		}
+	}
+}
+
+// preferHighest возвращает штрафы версий пакета за отставание от наибольшей.
+// Версии отсортированы по убыванию, копии одной версии - по приоритету репозитория,
+// поэтому штраф равен позиции версии в списке.
+func preferHighest(adapter *GophersatAdapter, versions []*pkg.Package) lexTerm {
+	var t lexTerm
+	for i, p := range versions {
+		if i > 0 {
+			t.lits = append(t.lits, adapter.PackageVar(p))
+			t.weights = append(t.weights, i)
+			t.max = i
+		}
+	}
+	return t
+}
+
+// fixedUse сообщает о флагах, которые autounmask не меняет: флагах из use.force
+// и use.mask и флагах установленных версий, которые нельзя пересобрать
+func (r *PortageResolver) fixedUse(p *pkg.Package, flag string) bool {
+	if r.vdbOnly[p] {
+		return true
+	}
+	if r.use == nil {
+		return false
+	}
+	return r.use.Forced(p)[flag] || r.use.Masked(p)[flag]
+}
+
+// collectChanges формирует изменения конфигурации для выбранных скрытых версий
+// и измененных решателем USE-флагов
+func (r *PortageResolver) collectChanges(result []*pkg.Package, changedUse map[*pkg.Package]map[string]bool) []visibility.Change {
+	var changes []visibility.Change
+	for _, p := range result {
+		for _, reason := range r.relaxed[p] {
+			changes = append(changes, *reason.Change)
+		}
+	}
+
+	for p, flags := range changedUse {
+		names := make([]string, 0, len(flags))
+		for flag := range flags {
+			names = append(names, flag)
+		}
+		sort.Strings(names)
+
+		entry := "=" + p.Name + "-" + p.Version
+		for _, flag := range names {
+			if flags[flag] {
+				entry += " " + flag
+			} else {
+				entry += " -" + flag
+			}
+		}
+		changes = append(changes, visibility.Change{
+			File:    "package.use",
+			Entry:   entry,
+			Comment: "USE changes required by dependencies",
+		})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].File != changes[j].File {
+			return changes[i].File < changes[j].File
+		}
+		return changes[i].Entry < changes[j].Entry
+	})
+	return changes
 }
@@ -0,0 +1,451 @@
+package solver
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kolkov/gportage/internal/config"
+	"github.com/kolkov/gportage/internal/pkg"
+	"github.com/kolkov/gportage/internal/repo"
+	"github.com/kolkov/gportage/internal/visibility"
+)
+
+// testRepo создает репозиторий из версий пакетов
+func testRepo(packages ...*pkg.Package) *repo.MockRepository {
+	r := repo.NewMockRepository()
+	for _, p := range packages {
+		r.AddPackage(p)
+	}
+	return r
+}
+
+// resolveVersions разрешает аргументы и возвращает выбранные версии по именам пакетов
+func resolveVersions(t *testing.T, r *PortageResolver, args ...string) map[string]string {
+	t.Helper()
+	result, err := r.Resolve(args)
+	if err != nil {
+		t.Fatalf("Resolve(%v) error: %v", args, err)
+	}
+	versions := make(map[string]string, len(result))
+	for _, p := range result {
+		versions[p.Name] = p.Version
+	}
+	return versions
+}
+
+// findPackage возвращает выбранную версию пакета из решения
+func findPackage(result []*pkg.Package, name string) *pkg.Package {
+	for _, p := range result {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// checkVersions сравнивает выбранные версии с ожидаемыми
+func checkVersions(t *testing.T, got, want map[string]string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("resolved %v, want %v", got, want)
+		return
+	}
+	for name, version := range want {
+		if got[name] != version {
+			t.Errorf("%s: resolved version %q, want %q", name, got[name], version)
+		}
+	}
+}
+
+// Оптимизация по нескольким критериям не должна портить ограничения, найденные раньше:
+// у пакетов с тремя и более версиями веса критериев больше 1
+func TestResolveManyVersions(t *testing.T) {
+	r := testRepo(
+		testPackage(t, "app-misc/p", "1", "dev-libs/q"),
+		testPackage(t, "app-misc/p", "2", "dev-libs/q"),
+		testPackage(t, "app-misc/p", "3", "dev-libs/q"),
+		testPackage(t, "dev-libs/q", "1", "<dev-libs/r-3"),
+		testPackage(t, "dev-libs/q", "2", "<dev-libs/r-3"),
+		testPackage(t, "dev-libs/q", "3", "<dev-libs/r-3"),
+		testPackage(t, "dev-libs/r", "1", ""),
+		testPackage(t, "dev-libs/r", "2", ""),
+		testPackage(t, "dev-libs/r", "3", ""),
+		testPackage(t, "dev-libs/r", "4", ""),
+	)
+
+	got := resolveVersions(t, NewResolver(r), "app-misc/p")
+	checkVersions(t, got, map[string]string{"app-misc/p": "3", "dev-libs/q": "3", "dev-libs/r": "2"})
+}
+
+// keywordedPackage создает версию пакета с KEYWORDS
+func keywordedPackage(t *testing.T, name, version, rdepend string, keywords ...string) *pkg.Package {
+	p := testPackage(t, name, version, rdepend)
+	p.Keywords = keywords
+	return p
+}
+
+// Критерии оптимизируются в порядке: изменения конфигурации, версии запрошенных пакетов,
+// замены установленных версий, версии зависимостей, порядок альтернатив || ( )
+func TestResolveObjectiveOrder(t *testing.T) {
+	tests := []struct {
+		name      string
+		packages  []*pkg.Package
+		installed []*pkg.Package
+		args      []string
+		want      map[string]string
+	}{
+		{
+			name: "highest target version",
+			packages: []*pkg.Package{
+				testPackage(t, "app-misc/p", "1", ""),
+				testPackage(t, "app-misc/p", "2", ""),
+				testPackage(t, "app-misc/p", "3", ""),
+			},
+			args: []string{"app-misc/p"},
+			want: map[string]string{"app-misc/p": "3"},
+		},
+		{
+			name: "target version before new dependencies",
+			packages: []*pkg.Package{
+				testPackage(t, "app-misc/p", "1", ""),
+				testPackage(t, "app-misc/p", "2", "dev-libs/new"),
+				testPackage(t, "dev-libs/new", "1", ""),
+			},
+			args: []string{"app-misc/p"},
+			want: map[string]string{"app-misc/p": "2", "dev-libs/new": "1"},
+		},
+		{
+			name: "keep installed dependency",
+			packages: []*pkg.Package{
+				testPackage(t, "app-misc/p", "1", "dev-libs/r"),
+				testPackage(t, "dev-libs/r", "1", ""),
+				testPackage(t, "dev-libs/r", "2", ""),
+				testPackage(t, "dev-libs/r", "3", ""),
+			},
+			installed: []*pkg.Package{testPackage(t, "dev-libs/r", "1", "")},
+			args:      []string{"app-misc/p"},
+			want:      map[string]string{"app-misc/p": "1", "dev-libs/r": "1"},
+		},
+		{
+			name: "upgrade installed target",
+			packages: []*pkg.Package{
+				testPackage(t, "app-misc/p", "1", ""),
+				testPackage(t, "app-misc/p", "2", ""),
+			},
+			installed: []*pkg.Package{testPackage(t, "app-misc/p", "1", "")},
+			args:      []string{"app-misc/p"},
+			want:      map[string]string{"app-misc/p": "2"},
+		},
+		{
+			name: "highest dependency version",
+			packages: []*pkg.Package{
+				testPackage(t, "app-misc/p", "1", "dev-libs/q"),
+				testPackage(t, "dev-libs/q", "1", ""),
+				testPackage(t, "dev-libs/q", "2", ""),
+				testPackage(t, "dev-libs/q", "3", ""),
+			},
+			args: []string{"app-misc/p"},
+			want: map[string]string{"app-misc/p": "1", "dev-libs/q": "3"},
+		},
+		{
+			name: "first any-of alternative",
+			packages: []*pkg.Package{
+				testPackage(t, "app-misc/p", "1", "|| ( dev-libs/b dev-libs/c )"),
+				testPackage(t, "dev-libs/b", "1", ""),
+				testPackage(t, "dev-libs/c", "1", ""),
+				testPackage(t, "dev-libs/c", "2", ""),
+			},
+			args: []string{"app-misc/p"},
+			want: map[string]string{"app-misc/p": "1", "dev-libs/b": "1"},
+		},
+		{
+			name: "installed any-of alternative",
+			packages: []*pkg.Package{
+				testPackage(t, "app-misc/p", "1", "|| ( dev-libs/b dev-libs/c )"),
+				testPackage(t, "dev-libs/b", "1", ""),
+				testPackage(t, "dev-libs/c", "1", ""),
+			},
+			installed: []*pkg.Package{testPackage(t, "dev-libs/c", "1", "")},
+			args:      []string{"app-misc/p"},
+			want:      map[string]string{"app-misc/p": "1", "dev-libs/c": "1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewResolver(testRepo(tt.packages...))
+			if tt.installed != nil {
+				r.SetInstalled(testRepo(tt.installed...))
+			}
+			checkVersions(t, resolveVersions(t, r, tt.args...), tt.want)
+		})
+	}
+}
+
+// Версии пакетов максимизируются лексикографически: запрошенные пакеты в порядке
+// аргументов, затем зависимости в порядке обнаружения. Сумма отставаний версий
+// была бы меньше при app-misc/a-1 и dev-libs/d1-1, но более ранний пакет важнее.
+func TestResolveLexicographicVersions(t *testing.T) {
+	r := NewResolver(testRepo(
+		testPackage(t, "app-misc/a", "1", ""),
+		testPackage(t, "app-misc/a", "2", "<app-misc/b-2"),
+		testPackage(t, "app-misc/b", "1", "dev-libs/d1 dev-libs/d2"),
+		testPackage(t, "app-misc/b", "2", "dev-libs/d1 dev-libs/d2"),
+		testPackage(t, "app-misc/b", "3", "dev-libs/d1 dev-libs/d2"),
+		testPackage(t, "dev-libs/d1", "1", ""),
+		testPackage(t, "dev-libs/d1", "2", "<dev-libs/d2-2"),
+		testPackage(t, "dev-libs/d2", "1", ""),
+		testPackage(t, "dev-libs/d2", "2", ""),
+		testPackage(t, "dev-libs/d2", "3", ""),
+	))
+
+	got := resolveVersions(t, r, "app-misc/a", "app-misc/b")
+	checkVersions(t, got, map[string]string{"app-misc/a": "2", "app-misc/b": "1", "dev-libs/d1": "2", "dev-libs/d2": "1"})
+
+	// Порядок аргументов задает приоритет
+	got = resolveVersions(t, r, "app-misc/b", "app-misc/a")
+	checkVersions(t, got, map[string]string{"app-misc/a": "1", "app-misc/b": "3", "dev-libs/d1": "2", "dev-libs/d2": "1"})
+}
+
+// Изменения конфигурации при autounmask важнее наибольшей версии запрошенного пакета
+func TestResolveAutounmaskPrefersVisible(t *testing.T) {
+	r := NewResolver(testRepo(
+		keywordedPackage(t, "app-misc/p", "1", "", "amd64"),
+		keywordedPackage(t, "app-misc/p", "2", "", "~amd64"),
+		keywordedPackage(t, "app-misc/q", "1", "", "~amd64"),
+	))
+	r.SetVisibility(visibility.NewFilter(visibility.NewKeywordRule("amd64", nil)))
+	r.SetAutounmask(true)
+
+	checkVersions(t, resolveVersions(t, r, "app-misc/p"), map[string]string{"app-misc/p": "1"})
+	if changes := r.Changes(); len(changes) != 0 {
+		t.Errorf("Changes() = %v, want none", changes)
+	}
+
+	checkVersions(t, resolveVersions(t, r, "app-misc/q"), map[string]string{"app-misc/q": "1"})
+	if changes := r.Changes(); len(changes) != 1 || changes[0].File != "package.accept_keywords" {
+		t.Errorf("Changes() = %v, want one package.accept_keywords entry", changes)
+	}
+}
+
+// autounmask предлагает изменение USE для наибольшей версии зависимости
+func TestResolveAutounmaskUse(t *testing.T) {
+	r := NewResolver(testRepo(
+		testPackage(t, "app-misc/x", "1", "dev-libs/a[static]"),
+		testPackage(t, "dev-libs/a", "1", "", "static"),
+		testPackage(t, "dev-libs/a", "2", "", "static"),
+		testPackage(t, "dev-libs/a", "3", "", "static"),
+	))
+	r.SetAutounmask(true)
+
+	checkVersions(t, resolveVersions(t, r, "app-misc/x"), map[string]string{"app-misc/x": "1", "dev-libs/a": "3"})
+	changes := r.Changes()
+	if len(changes) != 1 || changes[0].File != "package.use" || changes[0].Entry != "=dev-libs/a-3 static" {
+		t.Errorf("Changes() = %v, want package.use entry =dev-libs/a-3 static", changes)
+	}
+}
+
+// Версия из репозитория с наибольшим приоритетом переопределяет ту же версию
+// из остальных: ::repo может выбрать только версии, которые не переопределены
+func TestResolveRepositoryOverride(t *testing.T) {
+	composite := repo.NewCompositeRepository()
+	composite.Add("gentoo", -1000, testRepo(
+		testPackage(t, "app-misc/a", "1", "dev-libs/b"),
+		testPackage(t, "dev-libs/b", "1", ""),
+		testPackage(t, "dev-libs/b", "2", ""),
+	))
+	composite.Add("overlay", 50, testRepo(testPackage(t, "dev-libs/b", "2", "")))
+	r := NewResolver(composite)
+
+	tests := []struct {
+		arg     string
+		version string
+		repo    string // Пустая строка - разрешение невозможно
+	}{
+		{"app-misc/a", "2", "overlay"},
+		{"=dev-libs/b-2", "2", "overlay"},
+		{"dev-libs/b::gentoo", "1", "gentoo"},
+		{"=dev-libs/b-2::gentoo", "", ""},
+	}
+	for _, tt := range tests {
+		result, err := r.Resolve([]string{tt.arg})
+		if tt.repo == "" {
+			if err == nil {
+				t.Errorf("Resolve(%s) = %v, want error", tt.arg, result)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Resolve(%s) error: %v", tt.arg, err)
+			continue
+		}
+		if b := findPackage(result, "dev-libs/b"); b == nil || b.Version != tt.version || b.Repository != tt.repo {
+			t.Errorf("Resolve(%s): dev-libs/b = %v, want %s::%s", tt.arg, b, tt.version, tt.repo)
+		}
+	}
+}
+
+// Установленная версия, ebuild которой удален из репозитория, остается кандидатом
+func TestResolveInstalledWithoutEbuild(t *testing.T) {
+	tests := []struct {
+		name string
+		dep  string
+		want map[string]string
+	}{
+		{"keep installed", "dev-libs/r", map[string]string{"app-misc/p": "1", "dev-libs/r": "1"}},
+		{"upgrade when required", ">=dev-libs/r-2", map[string]string{"app-misc/p": "1", "dev-libs/r": "3"}},
+		{"removed package", "dev-libs/gone", map[string]string{"app-misc/p": "1", "dev-libs/gone": "1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewResolver(testRepo(
+				testPackage(t, "app-misc/p", "1", tt.dep),
+				testPackage(t, "dev-libs/r", "2", ""),
+				testPackage(t, "dev-libs/r", "3", ""),
+			))
+			r.SetInstalled(testRepo(
+				testPackage(t, "dev-libs/r", "1", ""),
+				testPackage(t, "dev-libs/gone", "1", ""),
+			))
+			result, err := r.Resolve([]string{"app-misc/p"})
+			if err != nil {
+				t.Fatalf("Resolve() error: %v", err)
+			}
+			got := make(map[string]string)
+			for _, p := range result {
+				got[p.Name] = p.Version
+			}
+			checkVersions(t, got, tt.want)
+			for _, p := range result {
+				if p.Name != "app-misc/p" && tt.want[p.Name] == "1" && r.Action(p) != "R" {
+					t.Errorf("Action(%s-%s) = %s, want R", p.Name, p.Version, r.Action(p))
+				}
+			}
+		})
+	}
+}
+
+// autounmask выбирает набор изменений конфигурации с наименьшим весом:
+// изменение USE дешевле ключевого слова, ключевое слово дешевле снятия маски
+func TestResolveAutounmaskWeights(t *testing.T) {
+	tests := []struct {
+		name    string
+		rdepend string
+		want    string // Выбранная зависимость
+		change  string // Единственное предложенное изменение
+	}{
+		{"USE before keyword", "|| ( dev-libs/testing dev-libs/use[ssl] )", "dev-libs/use", "package.use: =dev-libs/use-1 ssl"},
+		{"keyword before mask", "|| ( dev-libs/masked dev-libs/testing )", "dev-libs/testing", "package.accept_keywords: =dev-libs/testing-1 ~amd64"},
+		{"mask when required", "dev-libs/masked", "dev-libs/masked", "package.unmask: =dev-libs/masked-1"},
+		{"visible alternative", "|| ( dev-libs/testing dev-libs/stable )", "dev-libs/stable", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			use := testPackage(t, "dev-libs/use", "1", "", "ssl")
+			use.Keywords = []string{"amd64"}
+			masks := visibility.NewMaskRule()
+			masks.AddMasks([]config.Entry{{Fields: []string{"dev-libs/masked"}, Source: "package.mask:1"}})
+			r := NewResolver(testRepo(
+				keywordedPackage(t, "app-misc/app", "1", tt.rdepend, "amd64"),
+				keywordedPackage(t, "dev-libs/testing", "1", "", "~amd64"),
+				keywordedPackage(t, "dev-libs/masked", "1", "", "amd64"),
+				keywordedPackage(t, "dev-libs/stable", "1", "", "amd64"),
+				use,
+			))
+			r.SetVisibility(visibility.NewFilter(visibility.NewKeywordRule("amd64", []string{"amd64"}), masks))
+			r.SetAutounmask(true)
+
+			got := resolveVersions(t, r, "app-misc/app")
+			if got[tt.want] != "1" || len(got) != 2 {
+				t.Errorf("resolved %v, want app-misc/app and %s", got, tt.want)
+			}
+			var changes []string
+			for _, c := range r.Changes() {
+				changes = append(changes, c.String())
+			}
+			if tt.change == "" && len(changes) != 0 || tt.change != "" && (len(changes) != 1 || changes[0] != tt.change) {
+				t.Errorf("Changes() = %q, want %q", changes, tt.change)
+			}
+		})
+	}
+}
+
+// Разрешение графа из многих пакетов с несколькими версиями не должно расти экспоненциально:
+// критерий замен установленных версий не должен сводиться к подсчету всех выбранных версий
+func TestResolveScaling(t *testing.T) {
+	const count = 60
+	var packages, installed []*pkg.Package
+	want := make(map[string]string)
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("dev-libs/p%d", i)
+		dep := ""
+		if i+1 < count {
+			dep = fmt.Sprintf("dev-libs/p%d", i+1)
+		}
+		for _, version := range []string{"1", "2", "3"} {
+			packages = append(packages, testPackage(t, name, version, dep))
+		}
+		want[name] = "3"
+		// Установленные зависимости сохраняются
+		if i > 0 && i%3 == 0 {
+			installed = append(installed, testPackage(t, name, "1", dep))
+			want[name] = "1"
+		}
+	}
+
+	r := NewResolver(testRepo(packages...))
+	r.SetInstalled(testRepo(installed...))
+	type outcome struct {
+		result []*pkg.Package
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := r.Resolve([]string{"dev-libs/p0"})
+		done <- outcome{result, err}
+	}()
+	select {
+	case o := <-done:
+		if o.err != nil {
+			t.Fatalf("Resolve() error: %v", o.err)
+		}
+		got := make(map[string]string)
+		for _, p := range o.result {
+			got[p.Name] = p.Version
+		}
+		checkVersions(t, got, want)
+	case <-time.After(20 * time.Second):
+		t.Fatalf("Resolve() of %d packages did not finish in 20s", count)
+	}
+}
+
+// Зависимости от разных слотов пакета выбирают по версии в каждом слоте,
+// и все выбранные слоты устанавливаются раньше зависимого пакета
+func TestResolveMultipleSlots(t *testing.T) {
+	python := func(version, slot string) *pkg.Package {
+		p := testPackage(t, "dev-lang/python", version, "")
+		p.Slot = pkg.ParseSlot(slot)
+		return p
+	}
+	r := NewResolver(testRepo(
+		testPackage(t, "app-misc/app", "1", "dev-lang/python:3.11 dev-lang/python:3.12"),
+		python("3.11.8", "3.11"),
+		python("3.11.9", "3.11"),
+		python("3.12.4", "3.12"),
+	))
+
+	result, err := r.Resolve([]string{"app-misc/app"})
+	if err != nil {
+		t.Fatalf("Resolve() error: %v", err)
+	}
+	var got []string
+	for _, p := range r.MergeOrder(result) {
+		got = append(got, p.Name+"-"+p.Version+":"+p.Slot.Name)
+	}
+	want := []string{"dev-lang/python-3.11.9:3.11", "dev-lang/python-3.12.4:3.12", "app-misc/app-1:0"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("MergeOrder() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,112 @@
+package useflags
+
+import (
+	"strings"
+
+	"github.com/kolkov/gportage/internal/config"
+	"github.com/kolkov/gportage/internal/pkg"
+	"github.com/kolkov/gportage/internal/profile"
+)
+
+// Calculator вычисляет эффективные USE-флаги пакета.
+// Слои применяются в порядке Portage: значения по умолчанию из IUSE, make.defaults,
+// package.use профиля, make.conf, /etc/portage/package.use и переменная окружения USE.
+// Затем добавляются флаги use.force и убираются флаги use.mask (маска имеет приоритет).
+type Calculator struct {
+	ProfileUse        []string               // USE из make.defaults профиля
+	ProfilePackageUse []profile.PackageFlags // package.use профиля
+	ConfUse           []string               // USE из make.conf
+	PackageUse        []profile.PackageFlags // /etc/portage/package.use
+	EnvUse            []string               // Переменная окружения USE
+
+	Force        []string               // use.force
+	Mask         []string               // use.mask
+	PackageForce []profile.PackageFlags // package.use.force
+	PackageMask  []profile.PackageFlags // package.use.mask
+}
+
+// NewCalculator создает калькулятор из профиля и make.conf; prof и cfg могут быть nil
+func NewCalculator(prof *profile.Profile, cfg *config.Config) *Calculator {
+	c := &Calculator{}
+	if prof != nil {
+		c.ProfileUse = prof.Incremental["USE"]
+		c.ProfilePackageUse = prof.PackageUse
+		c.Force = prof.UseForce
+		c.Mask = prof.UseMask
+		c.PackageForce = prof.PackageUseForce
+		c.PackageMask = prof.PackageUseMask
+	}
+	if cfg != nil {
+		c.ConfUse = cfg.Local["USE"]
+	}
+	return c
+}
+
+// LoadPackageUse добавляет записи package.use (файл или каталог)
+func (c *Calculator) LoadPackageUse(path string) error {
+	flags, err := profile.ReadPackageFlags(path)
+	if err != nil {
+		return err
+	}
+	c.PackageUse = append(c.PackageUse, flags...)
+	return nil
+}
+
+// Effective возвращает состояние каждого флага IUSE пакета
+func (c *Calculator) Effective(p *pkg.Package) map[string]bool {
+	var layers []string
+	for _, flag := range p.IUse {
+		if strings.HasPrefix(flag, "+") {
+			layers = append(layers, flag[1:])
+		}
+	}
+	layers = append(layers, c.ProfileUse...)
+	layers = append(layers, matching(c.ProfilePackageUse, p)...)
+	layers = append(layers, c.ConfUse...)
+	layers = append(layers, matching(c.PackageUse, p)...)
+	layers = append(layers, c.EnvUse...)
+
+	enabled := toSet(config.StackIncremental(layers))
+	forced := c.Forced(p)
+	masked := c.Masked(p)
+
+	use := make(map[string]bool, len(p.IUse))
+	for _, flag := range p.IUseNames() {
+		use[flag] = (enabled[flag] || forced[flag]) && !masked[flag]
+	}
+	return use
+}
+
+// Apply заменяет UseFlags пакета эффективными значениями
+func (c *Calculator) Apply(p *pkg.Package) {
+	p.UseFlags = c.Effective(p)
+}
+
+// Forced возвращает флаги, принудительно включенные профилем для пакета
+func (c *Calculator) Forced(p *pkg.Package) map[string]bool {
+	return toSet(config.StackIncremental(append(append([]string(nil), c.Force...), matching(c.PackageForce, p)...)))
+}
+
+// Masked возвращает флаги, замаскированные профилем для пакета
+func (c *Calculator) Masked(p *pkg.Package) map[string]bool {
+	return toSet(config.StackIncremental(append(append([]string(nil), c.Mask...), matching(c.PackageMask, p)...)))
+}
+
+// matching собирает флаги записей, атомы которых соответствуют пакету
+func matching(entries []profile.PackageFlags, p *pkg.Package) []string {
+	var flags []string
+	for _, e := range entries {
+		if e.Atom.Match(p) {
+			flags = append(flags, e.Flags...)
+		}
+	}
+	return flags
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
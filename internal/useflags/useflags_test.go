@@ -0,0 +1,80 @@
+package useflags
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kolkov/gportage/internal/pkg"
+	"github.com/kolkov/gportage/internal/profile"
+)
+
+// packageFlags разбирает записи вида "atom flag..." в формате package.use
+func packageFlags(t *testing.T, lines ...string) []profile.PackageFlags {
+	t.Helper()
+	var entries []profile.PackageFlags
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		atom, err := pkg.ParseAtom(fields[0])
+		if err != nil {
+			t.Fatalf("ParseAtom(%q): %v", fields[0], err)
+		}
+		entries = append(entries, profile.PackageFlags{Atom: atom, Flags: fields[1:]})
+	}
+	return entries
+}
+
+// Слои USE применяются в порядке: IUSE, make.defaults, package.use профиля, make.conf,
+// package.use пользователя, окружение; затем use.force и use.mask с приоритетом маски
+func TestEffective(t *testing.T) {
+	tests := []struct {
+		name string
+		c    Calculator
+		want string // Включенные флаги IUSE "+a b c d" в алфавитном порядке
+	}{
+		{"IUSE defaults", Calculator{}, "a"},
+		{"profile USE", Calculator{ProfileUse: []string{"b"}}, "a b"},
+		{"make.conf disables IUSE default", Calculator{ConfUse: []string{"-a"}}, ""},
+		{"make.conf overrides profile package.use",
+			Calculator{ProfilePackageUse: packageFlags(t, "app-misc/foo c"), ConfUse: []string{"-c"}}, "a"},
+		{"profile package.use overrides profile USE",
+			Calculator{ProfileUse: []string{"b"}, ProfilePackageUse: packageFlags(t, "app-misc/foo -b")}, "a"},
+		{"package.use overrides make.conf",
+			Calculator{ConfUse: []string{"-b"}, PackageUse: packageFlags(t, ">=app-misc/foo-1 b")}, "a b"},
+		{"environment overrides package.use",
+			Calculator{PackageUse: packageFlags(t, "app-misc/foo c"), EnvUse: []string{"-c"}}, "a"},
+		{"non-matching package.use",
+			Calculator{PackageUse: packageFlags(t, "app-misc/bar c", "<app-misc/foo-1 d")}, "a"},
+		{"-* in make.conf clears earlier layers",
+			Calculator{ProfileUse: []string{"b"}, ConfUse: []string{"-*", "c"}}, "c"},
+		{"-* in package.use",
+			Calculator{ConfUse: []string{"b", "c"}, PackageUse: packageFlags(t, "app-misc/foo -* d")}, "d"},
+		{"use.force overrides user USE",
+			Calculator{ConfUse: []string{"-a", "-b"}, Force: []string{"b"}}, "b"},
+		{"use.mask overrides use.force and USE",
+			Calculator{ConfUse: []string{"c"}, Force: []string{"b"}, Mask: []string{"b", "c"}}, "a"},
+		{"package.use.force and package.use.mask",
+			Calculator{PackageForce: packageFlags(t, "app-misc/foo c", "app-misc/bar d"), PackageMask: packageFlags(t, "app-misc/foo a")}, "c"},
+		{"package.use.mask unmasks a global mask",
+			Calculator{ConfUse: []string{"b", "c"}, Mask: []string{"b", "c"}, PackageMask: packageFlags(t, "app-misc/foo -c")}, "a c"},
+		{"package.use.force -* drops global forces",
+			Calculator{Force: []string{"b"}, PackageForce: packageFlags(t, "app-misc/foo -* d")}, "a d"},
+	}
+	for _, tt := range tests {
+		p := pkg.NewPackage("app-misc/foo", "1.0", "0")
+		p.IUse = []string{"+a", "b", "c", "d"}
+
+		use := tt.c.Effective(p)
+		var enabled []string
+		for _, flag := range []string{"a", "b", "c", "d"} {
+			if use[flag] {
+				enabled = append(enabled, flag)
+			}
+		}
+		if len(use) != 4 {
+			t.Errorf("%s: Effective() = %v, want all IUSE flags", tt.name, use)
+		}
+		if got := strings.Join(enabled, " "); got != tt.want {
+			t.Errorf("%s: enabled flags %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package vdb
+
+import (
+	"bufio"
+	"compress/bzip2"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kolkov/gportage/internal/pkg"
+	"github.com/kolkov/gportage/internal/repo"
+)
+
+// DefaultPath - расположение базы установленных пакетов
+const DefaultPath = "/var/db/pkg"
+
+// vdbKeys - файлы записи VDB, которые читаются в метаданные пакета
+var vdbKeys = []string{
+	"BDEPEND", "DEPEND", "EAPI", "IDEPEND", "IUSE", "KEYWORDS", "LICENSE",
+	"PDEPEND", "RDEPEND", "REQUIRED_USE", "SLOT",
+}
+
+// DB читает базу установленных пакетов /var/db/pkg/<category>/<pf>/.
+// Реализует repo.Repository, поэтому может объединяться с репозиторием ebuild.
+type DB struct {
+	Path string
+}
+
+var _ repo.Repository = (*DB)(nil)
+
+func New(path string) *DB {
+	return &DB{Path: path}
+}
+
+func (db *DB) LoadPackages(names []string) ([]*pkg.Package, error) {
+	var packages []*pkg.Package
+	for _, name := range names {
+		p, err := db.LoadPackage(name)
+		if err != nil {
+			return nil, err
+		}
+		packages = append(packages, p)
+	}
+	return packages, nil
+}
+
+func (db *DB) LoadPackage(name string) (*pkg.Package, error) {
+	versions, err := db.LoadVersions(name)
+	if err != nil {
+		return nil, err
+	}
+	return versions[0], nil
+}
+
+// LoadVersions возвращает установленные версии пакета, отсортированные по убыванию.
+// Записи, которые не удалось прочитать, пропускаются с предупреждением.
+func (db *DB) LoadVersions(name string) ([]*pkg.Package, error) {
+	category, pkgName, found := strings.Cut(name, "/")
+	if !found {
+		return nil, fmt.Errorf("invalid package name: %s", name)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(db.Path, category))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s is not installed", repo.ErrPackageNotFound, name)
+		}
+		return nil, err
+	}
+
+	var packages []*pkg.Package
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		pn, version, ok := pkg.SplitPackageVersion(e.Name())
+		if !ok || pn != pkgName {
+			continue
+		}
+		p, err := db.load(name, version)
+		if err != nil {
+			log.Printf("Warning: skipping invalid VDB entry %s/%s: %v", category, e.Name(), err)
+			continue
+		}
+		packages = append(packages, p)
+	}
+
+	if len(packages) == 0 {
+		return nil, fmt.Errorf("%w: %s is not installed", repo.ErrPackageNotFound, name)
+	}
+	pkg.SortByVersion(packages)
+	return packages, nil
+}
+
+// Installed возвращает все установленные пакеты, упорядоченные по имени.
+// Записи, которые не удалось прочитать, пропускаются с предупреждением.
+func (db *DB) Installed() ([]*pkg.Package, error) {
+	categories, err := os.ReadDir(db.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var packages []*pkg.Package
+	for _, c := range categories {
+		if !c.IsDir() {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(db.Path, c.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			// Каталоги вида -MERGING-pf остаются после прерванной установки
+			if !e.IsDir() || strings.HasPrefix(e.Name(), "-MERGING-") {
+				continue
+			}
+			pn, version, ok := pkg.SplitPackageVersion(e.Name())
+			if !ok {
+				log.Printf("Warning: skipping invalid VDB entry %s/%s", c.Name(), e.Name())
+				continue
+			}
+			p, err := db.load(c.Name()+"/"+pn, version)
+			if err != nil {
+				log.Printf("Warning: skipping invalid VDB entry %s/%s: %v", c.Name(), e.Name(), err)
+				continue
+			}
+			packages = append(packages, p)
+		}
+	}
+
+	sort.SliceStable(packages, func(i, j int) bool {
+		if packages[i].Name != packages[j].Name {
+			return packages[i].Name < packages[j].Name
+		}
+		return pkg.CompareVersions(packages[i].Version, packages[j].Version) > 0
+	})
+	return packages, nil
+}
+
+// dir возвращает каталог записи VDB для версии пакета
+func (db *DB) dir(name, version string) string {
+	category, pn, _ := strings.Cut(name, "/")
+	return filepath.Join(db.Path, category, pn+"-"+version)
+}
+
+// load читает запись VDB в пакет; USE содержит включенные при сборке флаги
+func (db *DB) load(name, version string) (*pkg.Package, error) {
+	dir := db.dir(name, version)
+
+	md := repo.NewMetadata()
+	for _, key := range vdbKeys {
+		value, err := readValue(filepath.Join(dir, key))
+		if err != nil {
+			return nil, fmt.Errorf("invalid VDB entry %s-%s: %w", name, version, err)
+		}
+		if value != "" {
+			md.Vars[key] = value
+		}
+	}
+
+	// Установленная версия остается в базе даже с неизвестным EAPI
+	// или некорректными метаданными, иначе решатель считал бы ее не установленной
+	p := repo.NewInstalledPackage(name, version, md)
+
+	use, err := readValue(filepath.Join(dir, "USE"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid VDB entry %s-%s: %w", name, version, err)
+	}
+	enabled := make(map[string]bool)
+	for _, flag := range strings.Fields(use) {
+		enabled[flag] = true
+	}
+	for flag := range p.UseFlags {
+		p.UseFlags[flag] = enabled[flag]
+	}
+
+	if p.Repository, err = readValue(filepath.Join(dir, "repository")); err != nil {
+		return nil, fmt.Errorf("invalid VDB entry %s-%s: %w", name, version, err)
+	}
+	return p, nil
+}
+
+// readValue читает однострочный файл записи VDB; отсутствующий файл дает пустое значение
+func readValue(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.Join(strings.Fields(string(content)), " "), nil
+}
+
+// BuildTime возвращает время сборки установленной версии (BUILD_TIME)
+func (db *DB) BuildTime(p *pkg.Package) (time.Time, error) {
+	value, err := readValue(filepath.Join(db.dir(p.Name, p.Version), "BUILD_TIME"))
+	if err != nil || value == "" {
+		return time.Time{}, err
+	}
+	sec, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid BUILD_TIME for %s-%s: %w", p.Name, p.Version, err)
+	}
+	return time.Unix(sec, 0), nil
+}
+
+// Environment возвращает сохраненное окружение сборки (environment.bz2).
+// Если файла нет, возвращается ошибка, удовлетворяющая os.IsNotExist.
+func (db *DB) Environment(p *pkg.Package) (string, error) {
+	f, err := os.Open(filepath.Join(db.dir(p.Name, p.Version), "environment.bz2"))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	content, err := io.ReadAll(bzip2.NewReader(f))
+	if err != nil {
+		return "", fmt.Errorf("invalid environment.bz2 for %s-%s: %w", p.Name, p.Version, err)
+	}
+	return string(content), nil
+}
+
+// ContentType определяет тип записи CONTENTS
+type ContentType int
+
+const (
+	ContentDir ContentType = iota // dir /path
+	ContentObj                    // obj /path md5 mtime
+	ContentSym                    // sym /path -> target mtime
+	ContentFif                    // fif /path
+	ContentDev                    // dev /path
+)
+
+// ContentEntry - файл, установленный пакетом
+type ContentEntry struct {
+	Type   ContentType
+	Path   string
+	MD5    string    // Для obj
+	Target string    // Для sym
+	MTime  time.Time // Для obj и sym
+}
+
+// Contents читает список файлов установленной версии пакета (CONTENTS)
+func (db *DB) Contents(p *pkg.Package) ([]ContentEntry, error) {
+	f, err := os.Open(filepath.Join(db.dir(p.Name, p.Version), "CONTENTS"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []ContentEntry
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		entry, err := parseContentsLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("CONTENTS of %s-%s, line %d: %w", p.Name, p.Version, lineNo, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// parseContentsLine разбирает строку CONTENTS.
+// Пути могут содержать пробелы, поэтому md5, mtime и цель ссылки отделяются с конца строки.
+func parseContentsLine(line string) (ContentEntry, error) {
+	kind, rest, _ := strings.Cut(line, " ")
+	switch kind {
+	case "dir":
+		return ContentEntry{Type: ContentDir, Path: rest}, nil
+	case "fif":
+		return ContentEntry{Type: ContentFif, Path: rest}, nil
+	case "dev":
+		return ContentEntry{Type: ContentDev, Path: rest}, nil
+
+	case "obj":
+		i := strings.LastIndex(rest, " ")
+		j := strings.LastIndex(rest[:max(i, 0)], " ")
+		if i < 0 || j < 0 {
+			return ContentEntry{}, fmt.Errorf("malformed obj entry %q", line)
+		}
+		mtime, err := parseMTime(rest[i+1:])
+		if err != nil {
+			return ContentEntry{}, err
+		}
+		return ContentEntry{Type: ContentObj, Path: rest[:j], MD5: rest[j+1 : i], MTime: mtime}, nil
+
+	case "sym":
+		i := strings.LastIndex(rest, " ")
+		link, target, found := strings.Cut(rest[:max(i, 0)], " -> ")
+		if i < 0 || !found {
+			return ContentEntry{}, fmt.Errorf("malformed sym entry %q", line)
+		}
+		mtime, err := parseMTime(rest[i+1:])
+		if err != nil {
+			return ContentEntry{}, err
+		}
+		return ContentEntry{Type: ContentSym, Path: link, Target: target, MTime: mtime}, nil
+	}
+	return ContentEntry{}, fmt.Errorf("unknown CONTENTS entry type %q", kind)
+}
+
+func parseMTime(s string) (time.Time, error) {
+	sec, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid mtime %q", s)
+	}
+	return time.Unix(sec, 0), nil
+}
@@ -0,0 +1,102 @@
+package vdb
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kolkov/gportage/internal/repo"
+)
+
+// writeEntry создает запись VDB category/pf с файлами метаданных
+func writeEntry(t *testing.T, root, cpf string, files map[string]string) {
+	t.Helper()
+	dir := filepath.Join(root, cpf)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Записи с неизвестным EAPI или некорректными метаданными остаются установленными
+// с той частью метаданных, которую удалось разобрать; нечитаемые записи пропускаются
+func TestKeepEntriesWithInvalidMetadata(t *testing.T) {
+	root := t.TempDir()
+	writeEntry(t, root, "sys-libs/zlib-1.3", map[string]string{"EAPI": "8", "SLOT": "0/1", "IUSE": "static-libs", "USE": "static-libs amd64"})
+	writeEntry(t, root, "sys-libs/zlib-1.2", map[string]string{"EAPI": "99", "SLOT": "0", "IUSE": "+minizip", "USE": "minizip", "RDEPEND": "dev-libs/foo"})
+	writeEntry(t, root, "app-misc/hello-2.10", map[string]string{"EAPI": "8", "SLOT": "0", "RDEPEND": "|| (", "LICENSE": "GPL-3"})
+	writeEntry(t, root, "app-misc/broken-1", nil)
+	if err := os.Mkdir(filepath.Join(root, "app-misc/broken-1", "SLOT"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	db := New(root)
+
+	installed, err := db.Installed()
+	if err != nil {
+		t.Fatalf("Installed() error: %v", err)
+	}
+	var got []string
+	for _, p := range installed {
+		got = append(got, p.Name+"-"+p.Version)
+	}
+	want := []string{"app-misc/hello-2.10", "sys-libs/zlib-1.3", "sys-libs/zlib-1.2"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Fatalf("Installed() = %v, want %v", got, want)
+	}
+
+	if p := installed[1]; !p.UseFlags["static-libs"] || p.Slot.Subslot != "1" || p.EAPI == nil {
+		t.Errorf("sys-libs/zlib-1.3: USE = %v, SLOT = %s, EAPI = %v", p.UseFlags, p.Slot, p.EAPI)
+	}
+	if p := installed[2]; p.EAPI != nil || !p.UseFlags["minizip"] || p.RDepend == nil {
+		t.Errorf("sys-libs/zlib-1.2: EAPI = %v, USE = %v, RDEPEND = %v; want unknown EAPI with parsed metadata", p.EAPI, p.UseFlags, p.RDepend)
+	}
+	if p := installed[0]; p.RDepend != nil || p.License == nil {
+		t.Errorf("app-misc/hello-2.10: RDEPEND = %v, LICENSE = %v; want only the invalid RDEPEND dropped", p.RDepend, p.License)
+	}
+
+	versions, err := db.LoadVersions("sys-libs/zlib")
+	if err != nil || len(versions) != 2 {
+		t.Errorf("LoadVersions(sys-libs/zlib) = %v, %v; want two versions", versions, err)
+	}
+	if _, err := db.LoadVersions("app-misc/broken"); !errors.Is(err, repo.ErrPackageNotFound) {
+		t.Errorf("LoadVersions(app-misc/broken) error = %v, want ErrPackageNotFound", err)
+	}
+	if _, err := db.LoadVersions("dev-libs/openssl"); !errors.Is(err, repo.ErrPackageNotFound) {
+		t.Errorf("LoadVersions(dev-libs/openssl) error = %v, want ErrPackageNotFound", err)
+	}
+}
+
+func TestParseContentsLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want ContentEntry
+	}{
+		{"dir /usr/share/doc", ContentEntry{Type: ContentDir, Path: "/usr/share/doc"}},
+		{"obj /usr/bin/my tool d41d8cd98f00b204e9800998ecf8427e 1700000000",
+			ContentEntry{Type: ContentObj, Path: "/usr/bin/my tool", MD5: "d41d8cd98f00b204e9800998ecf8427e"}},
+		{"sym /usr/lib/libz.so -> libz.so.1 1700000000",
+			ContentEntry{Type: ContentSym, Path: "/usr/lib/libz.so", Target: "libz.so.1"}},
+	}
+	for _, tt := range tests {
+		got, err := parseContentsLine(tt.line)
+		if err != nil {
+			t.Errorf("parseContentsLine(%q) error: %v", tt.line, err)
+			continue
+		}
+		got.MTime = tt.want.MTime
+		if got != tt.want {
+			t.Errorf("parseContentsLine(%q) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+
+	for _, line := range []string{"obj /usr/bin/foo", "sym /usr/lib/libz.so 1700000000", "lnk /x"} {
+		if _, err := parseContentsLine(line); err == nil {
+			t.Errorf("parseContentsLine(%q) succeeded, want error", line)
+		}
+	}
+}
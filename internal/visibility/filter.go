@@ -0,0 +1,92 @@
+package visibility
+
+import (
+	"github.com/kolkov/gportage/internal/pkg"
+)
+
+// ReasonKind определяет причину, по которой версия пакета скрыта
+type ReasonKind int
+
+const (
+	ReasonKeyword ReasonKind = iota
+	ReasonMask
+	ReasonLicense
+)
+
+// Reason описывает, почему версия пакета недоступна для установки
+type Reason struct {
+	Kind    ReasonKind
+	Message string
+	Source  string  // Файл конфигурации, из-за которого пакет скрыт (если известен)
+	Change  *Change // Изменение конфигурации, снимающее причину (для autounmask)
+}
+
+// Change - строка, которую нужно добавить в файл конфигурации, чтобы снять ограничение
+type Change struct {
+	File    string // Имя файла в каталоге конфигурации: package.accept_keywords, package.unmask, ...
+	Entry   string // Добавляемая строка, например "=app-misc/foo-1.0 ~amd64"
+	Comment string // Пояснение, записываемое над строкой
+}
+
+func (c Change) String() string {
+	return c.File + ": " + c.Entry
+}
+
+func (r Reason) String() string {
+	return r.Message
+}
+
+// Rule - одно правило видимости (ключевые слова, маски, лицензии)
+type Rule interface {
+	// Check возвращает причину сокрытия пакета или nil, если пакет видим
+	Check(p *pkg.Package) *Reason
+}
+
+// Filter объединяет правила видимости и применяется между репозиторием и решателем
+type Filter struct {
+	rules []Rule
+}
+
+func NewFilter(rules ...Rule) *Filter {
+	return &Filter{rules: rules}
+}
+
+// AddRule добавляет правило видимости
+func (f *Filter) AddRule(rule Rule) {
+	f.rules = append(f.rules, rule)
+}
+
+// Check возвращает все причины, по которым пакет скрыт; пустой список означает, что пакет видим
+func (f *Filter) Check(p *pkg.Package) []Reason {
+	if f == nil {
+		return nil
+	}
+
+	var reasons []Reason
+	for _, rule := range f.rules {
+		if reason := rule.Check(p); reason != nil {
+			reasons = append(reasons, *reason)
+		}
+	}
+	return reasons
+}
+
+// Hidden описывает скрытую версию пакета вместе с причинами
+type Hidden struct {
+	Package *pkg.Package
+	Reasons []Reason
+}
+
+// Split разделяет версии пакета на видимые и скрытые
+func (f *Filter) Split(versions []*pkg.Package) ([]*pkg.Package, []Hidden) {
+	var visible []*pkg.Package
+	var hidden []Hidden
+	for _, p := range versions {
+		if reasons := f.Check(p); len(reasons) > 0 {
+			hidden = append(hidden, Hidden{Package: p, Reasons: reasons})
+			continue
+		}
+		visible = append(visible, p)
+	}
+	return visible, hidden
+}
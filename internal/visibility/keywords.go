@@ -0,0 +1,154 @@
+package visibility
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/kolkov/gportage/internal/config"
+	"github.com/kolkov/gportage/internal/pkg"
+)
+
+// packageKeywords - запись package.accept_keywords
+type packageKeywords struct {
+	atom     *pkg.Atom
+	keywords []string
+}
+
+// KeywordRule скрывает версии, ключевые слова которых не приняты ACCEPT_KEYWORDS
+type KeywordRule struct {
+	Arch            string   // Архитектура системы, например amd64
+	AcceptKeywords  []string // ACCEPT_KEYWORDS после применения инкрементальных правил
+	packageKeywords []packageKeywords
+}
+
+// NewKeywordRule создает правило; пустой ACCEPT_KEYWORDS означает стабильную ветку arch
+func NewKeywordRule(arch string, acceptKeywords []string) *KeywordRule {
+	accept := config.StackIncremental(acceptKeywords)
+	if len(accept) == 0 && arch != "" {
+		accept = []string{arch}
+	}
+	return &KeywordRule{
+		Arch:           arch,
+		AcceptKeywords: accept,
+	}
+}
+
+// LoadPackageKeywords читает /etc/portage/package.accept_keywords (файл или каталог)
+func (r *KeywordRule) LoadPackageKeywords(path string) error {
+	entries, err := config.ReadEntries(path)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		atom, err := pkg.ParseAtom(e.Fields[0])
+		if err != nil {
+			log.Printf("Warning: %s: %v", e.Source, err)
+			continue
+		}
+		// Запись без ключевых слов принимает тестовую ветку текущей архитектуры
+		keywords := e.Fields[1:]
+		if len(keywords) == 0 {
+			keywords = []string{"~" + r.Arch}
+		}
+		r.packageKeywords = append(r.packageKeywords, packageKeywords{atom: atom, keywords: keywords})
+	}
+	return nil
+}
+
+// accepted возвращает принятые ключевые слова для пакета с учетом package.accept_keywords
+func (r *KeywordRule) accepted(p *pkg.Package) []string {
+	accept := r.AcceptKeywords
+	for _, pk := range r.packageKeywords {
+		if pk.atom.Match(p) {
+			accept = config.StackIncremental(append(append([]string{}, accept...), pk.keywords...))
+		}
+	}
+	return accept
+}
+
+// Check реализует алгоритм сопоставления ключевых слов Portage
+func (r *KeywordRule) Check(p *pkg.Package) *Reason {
+	accept := r.accepted(p)
+
+	acceptSet := make(map[string]bool, len(accept))
+	hasStable, hasTesting := false, false
+	for _, kw := range accept {
+		acceptSet[kw] = true
+		if strings.HasPrefix(kw, "~") {
+			// Принятая тестовая ветка включает и стабильную
+			acceptSet[kw[1:]] = true
+			hasTesting = true
+			hasStable = true
+		} else {
+			hasStable = true
+		}
+	}
+
+	if acceptSet["**"] {
+		return nil
+	}
+
+	for _, kw := range p.Keywords {
+		switch {
+		case strings.HasPrefix(kw, "-"):
+			continue
+		case kw == "*":
+			// Пакет стабилен на всех архитектурах
+			if hasStable {
+				return nil
+			}
+		case kw == "~*":
+			if hasTesting {
+				return nil
+			}
+		case acceptSet[kw]:
+			return nil
+		case acceptSet["*"] && !strings.HasPrefix(kw, "~"):
+			return nil
+		case acceptSet["~*"] && strings.HasPrefix(kw, "~"):
+			return nil
+		}
+	}
+
+	message := r.describe(p)
+	return &Reason{
+		Kind:    ReasonKeyword,
+		Message: message,
+		Change: &Change{
+			File:    "package.accept_keywords",
+			Entry:   fmt.Sprintf("=%s-%s %s", p.Name, p.Version, r.unmaskKeyword(p)),
+			Comment: message,
+		},
+	}
+}
+
+// unmaskKeyword возвращает ключевое слово, которое делает пакет видимым:
+// ~arch для тестовых версий, иначе ** (любые ключевые слова)
+func (r *KeywordRule) unmaskKeyword(p *pkg.Package) string {
+	for _, kw := range p.Keywords {
+		if kw == "~"+r.Arch {
+			return kw
+		}
+	}
+	return "**"
+}
+
+// describe формирует сообщение в стиле Portage: "masked by: ~amd64 keyword"
+func (r *KeywordRule) describe(p *pkg.Package) string {
+	if len(p.Keywords) == 0 {
+		return "masked by: missing keyword"
+	}
+	for _, kw := range p.Keywords {
+		if kw == "-*" || kw == "-"+r.Arch {
+			return fmt.Sprintf("masked by: %s keyword", kw)
+		}
+	}
+	for _, kw := range p.Keywords {
+		if kw == "~"+r.Arch {
+			return fmt.Sprintf("masked by: %s keyword", kw)
+		}
+	}
+	return fmt.Sprintf("masked by: missing keyword (KEYWORDS=%q)", strings.Join(p.Keywords, " "))
+}
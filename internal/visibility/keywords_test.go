@@ -0,0 +1,85 @@
+package visibility
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kolkov/gportage/internal/pkg"
+)
+
+// writeConfig записывает файл конфигурации во временный каталог и возвращает путь
+func writeConfig(t *testing.T, name string, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestKeywordRuleCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		accept   string // ACCEPT_KEYWORDS
+		keywords string // KEYWORDS пакета app-misc/foo-1.0
+		pkgKw    []string
+		message  string // Пустая строка - пакет видим
+		change   string
+	}{
+		{name: "stable", keywords: "amd64 ~x86"},
+		{name: "testing not accepted", keywords: "~amd64 x86",
+			message: "masked by: ~amd64 keyword", change: "=app-misc/foo-1.0 ~amd64"},
+		{name: "testing accepted", accept: "~amd64", keywords: "~amd64"},
+		{name: "testing branch accepts stable", accept: "~amd64", keywords: "amd64"},
+		{name: "other arch", keywords: "x86 ~arm",
+			message: `masked by: missing keyword (KEYWORDS="x86 ~arm")`, change: "=app-misc/foo-1.0 **"},
+		{name: "no keywords", message: "masked by: missing keyword", change: "=app-misc/foo-1.0 **"},
+		{name: "-* only", keywords: "-*", message: "masked by: -* keyword", change: "=app-misc/foo-1.0 **"},
+		{name: "-* with arch", keywords: "-* amd64"},
+		{name: "-arch", keywords: "-amd64 x86", message: "masked by: -amd64 keyword", change: "=app-misc/foo-1.0 **"},
+		{name: "* stable everywhere", keywords: "*"},
+		{name: "~* with stable branch", keywords: "~*",
+			message: `masked by: missing keyword (KEYWORDS="~*")`, change: "=app-misc/foo-1.0 **"},
+		{name: "~* with testing branch", accept: "~amd64", keywords: "~*"},
+		{name: "** accepts no keywords", accept: "**"},
+		{name: "* accepts any stable", accept: "*", keywords: "x86"},
+		{name: "* rejects testing", accept: "*", keywords: "~x86",
+			message: `masked by: missing keyword (KEYWORDS="~x86")`, change: "=app-misc/foo-1.0 **"},
+		{name: "~* accepts any testing", accept: "~*", keywords: "~x86"},
+		{name: "incremental -* in ACCEPT_KEYWORDS", accept: "~amd64 -* amd64", keywords: "~amd64",
+			message: "masked by: ~amd64 keyword", change: "=app-misc/foo-1.0 ~amd64"},
+		{name: "package.accept_keywords default ~arch", keywords: "~amd64", pkgKw: []string{"app-misc/foo"}},
+		{name: "package.accept_keywords other package", keywords: "~amd64", pkgKw: []string{"app-misc/bar", "<app-misc/foo-1.0"},
+			message: "masked by: ~amd64 keyword", change: "=app-misc/foo-1.0 ~amd64"},
+		{name: "package.accept_keywords **", pkgKw: []string{"=app-misc/foo-1.0 **"}},
+		{name: "package.accept_keywords removes a keyword", keywords: "amd64", pkgKw: []string{"app-misc/foo -amd64"},
+			message: `masked by: missing keyword (KEYWORDS="amd64")`, change: "=app-misc/foo-1.0 **"},
+	}
+	for _, tt := range tests {
+		r := NewKeywordRule("amd64", strings.Fields(tt.accept))
+		if tt.pkgKw != nil {
+			if err := r.LoadPackageKeywords(writeConfig(t, "package.accept_keywords", tt.pkgKw...)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		p := pkg.NewPackage("app-misc/foo", "1.0", "0")
+		p.Keywords = strings.Fields(tt.keywords)
+
+		reason := r.Check(p)
+		switch {
+		case tt.message == "" && reason != nil:
+			t.Errorf("%s: Check() = %q, want visible", tt.name, reason.Message)
+		case tt.message != "" && reason == nil:
+			t.Errorf("%s: Check() = visible, want %q", tt.name, tt.message)
+		case reason != nil:
+			if reason.Kind != ReasonKeyword || reason.Message != tt.message {
+				t.Errorf("%s: Check() = %q, want %q", tt.name, reason.Message, tt.message)
+			}
+			if reason.Change == nil || reason.Change.File != "package.accept_keywords" || reason.Change.Entry != tt.change {
+				t.Errorf("%s: Change = %+v, want package.accept_keywords entry %q", tt.name, reason.Change, tt.change)
+			}
+		}
+	}
+}
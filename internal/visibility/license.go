@@ -0,0 +1,169 @@
+package visibility
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/kolkov/gportage/internal/config"
+	"github.com/kolkov/gportage/internal/pkg"
+)
+
+// packageLicenses - запись package.license
+type packageLicenses struct {
+	atom     *pkg.Atom
+	licenses []string
+}
+
+// LicenseRule скрывает версии, лицензии которых не приняты ACCEPT_LICENSE и package.license
+type LicenseRule struct {
+	AcceptLicense   []string            // Значения ACCEPT_LICENSE по слоям: "*", "-*", "@GROUP", "-LICENSE", ...
+	Groups          map[string][]string // license_groups: имя группы -> лицензии и вложенные группы (@NAME)
+	packageLicenses []packageLicenses
+}
+
+// NewLicenseRule создает правило для значений ACCEPT_LICENSE до объединения слоев:
+// "-LICENSE" должен исключать лицензию и из групп, принятых раньше
+func NewLicenseRule(acceptLicense []string) *LicenseRule {
+	return &LicenseRule{
+		AcceptLicense: acceptLicense,
+		Groups:        make(map[string][]string),
+	}
+}
+
+// LoadGroups читает profiles/license_groups; группы из более поздних файлов заменяют прежние
+func (r *LicenseRule) LoadGroups(path string) error {
+	entries, err := config.ReadEntries(path)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		r.Groups[e.Fields[0]] = e.Fields[1:]
+	}
+	return nil
+}
+
+// LoadPackageLicenses читает /etc/portage/package.license (файл или каталог)
+func (r *LicenseRule) LoadPackageLicenses(path string) error {
+	entries, err := config.ReadEntries(path)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		atom, err := pkg.ParseAtom(e.Fields[0])
+		if err != nil {
+			log.Printf("Warning: %s: %v", e.Source, err)
+			continue
+		}
+		r.packageLicenses = append(r.packageLicenses, packageLicenses{atom: atom, licenses: e.Fields[1:]})
+	}
+	return nil
+}
+
+// expand раскрывает группу лицензий с учетом вложенных групп
+func (r *LicenseRule) expand(group string, seen map[string]bool) []string {
+	if seen[group] {
+		return nil
+	}
+	seen[group] = true
+
+	members, ok := r.Groups[group]
+	if !ok {
+		log.Printf("Warning: unknown license group @%s", group)
+		return nil
+	}
+
+	var licenses []string
+	for _, m := range members {
+		if strings.HasPrefix(m, "@") {
+			licenses = append(licenses, r.expand(m[1:], seen)...)
+			continue
+		}
+		licenses = append(licenses, m)
+	}
+	return licenses
+}
+
+// acceptance - результат применения значений ACCEPT_LICENSE
+type acceptance struct {
+	all      bool            // Принято "*"
+	accepted map[string]bool // Явно принятые лицензии
+	rejected map[string]bool // Лицензии, исключенные после "*"
+}
+
+func (a *acceptance) ok(license string) bool {
+	return a.accepted[license] || (a.all && !a.rejected[license])
+}
+
+// accepted вычисляет принятые лицензии для пакета с учетом package.license
+func (r *LicenseRule) accepted(p *pkg.Package) *acceptance {
+	tokens := r.AcceptLicense
+	for _, pl := range r.packageLicenses {
+		if pl.atom.Match(p) {
+			tokens = append(append([]string{}, tokens...), pl.licenses...)
+		}
+	}
+
+	a := &acceptance{accepted: make(map[string]bool), rejected: make(map[string]bool)}
+	for _, token := range tokens {
+		negate := strings.HasPrefix(token, "-")
+		name := strings.TrimPrefix(token, "-")
+
+		switch {
+		case name == "*" && negate:
+			a.all = false
+			a.accepted = make(map[string]bool)
+			continue
+		case name == "*":
+			a.all = true
+			a.rejected = make(map[string]bool)
+			continue
+		}
+
+		licenses := []string{name}
+		if strings.HasPrefix(name, "@") {
+			licenses = r.expand(name[1:], make(map[string]bool))
+		}
+		for _, l := range licenses {
+			if negate {
+				delete(a.accepted, l)
+				a.rejected[l] = true
+			} else {
+				a.accepted[l] = true
+				delete(a.rejected, l)
+			}
+		}
+	}
+	return a
+}
+
+// Check скрывает пакет, если требование LICENSE не выполняется при его USE-флагах
+func (r *LicenseRule) Check(p *pkg.Package) *Reason {
+	if p.License == nil {
+		return nil
+	}
+
+	a := r.accepted(p)
+	licenses, ok := p.License.Licenses(p.UseFlags, a.ok)
+	if ok {
+		return nil
+	}
+
+	var missing []string
+	for _, l := range licenses {
+		if !a.ok(l) {
+			missing = append(missing, l)
+		}
+	}
+
+	message := fmt.Sprintf("masked by: %s license(s)", strings.Join(missing, " "))
+	return &Reason{
+		Kind:    ReasonLicense,
+		Message: message,
+		Change: &Change{
+			File:    "package.license",
+			Entry:   fmt.Sprintf("=%s-%s %s", p.Name, p.Version, strings.Join(missing, " ")),
+			Comment: message,
+		},
+	}
+}
@@ -0,0 +1,74 @@
+package visibility
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kolkov/gportage/internal/pkg"
+)
+
+func TestLicenseRuleAccepted(t *testing.T) {
+	groups := writeConfig(t, "license_groups",
+		"GPL-COMPATIBLE GPL-2 GPL-3 MIT",
+		"FREE @GPL-COMPATIBLE @OSI-APPROVED",
+		"OSI-APPROVED Apache-2.0 MIT",
+		"BINARY-REDISTRIBUTABLE @FREE freedist",
+	)
+
+	tests := []struct {
+		name     string
+		accept   []string // Значения ACCEPT_LICENSE по слоям
+		pkgLic   []string // package.license
+		accepted string
+		rejected string
+	}{
+		{name: "nothing accepted", rejected: "GPL-2 MIT"},
+		{name: "single license", accept: []string{"MIT"}, accepted: "MIT", rejected: "GPL-2"},
+		{name: "group", accept: []string{"@GPL-COMPATIBLE"}, accepted: "GPL-2 GPL-3 MIT", rejected: "Apache-2.0"},
+		{name: "nested groups", accept: []string{"@BINARY-REDISTRIBUTABLE"},
+			accepted: "GPL-2 GPL-3 MIT Apache-2.0 freedist", rejected: "EULA"},
+		{name: "-LICENSE after group", accept: []string{"@FREE -GPL-3"}, accepted: "GPL-2 MIT Apache-2.0", rejected: "GPL-3"},
+		{name: "-LICENSE in later layer", accept: []string{"@FREE", "-GPL-3"},
+			accepted: "GPL-2 MIT Apache-2.0", rejected: "GPL-3"},
+		{name: "-@GROUP", accept: []string{"@FREE -@OSI-APPROVED"}, accepted: "GPL-2 GPL-3", rejected: "MIT Apache-2.0"},
+		{name: "group after -LICENSE", accept: []string{"-MIT @GPL-COMPATIBLE"}, accepted: "MIT"},
+		{name: "*", accept: []string{"*"}, accepted: "EULA GPL-2"},
+		{name: "* with exclusion", accept: []string{"* -@GPL-COMPATIBLE"}, accepted: "EULA Apache-2.0", rejected: "GPL-2 MIT"},
+		{name: "-* clears", accept: []string{"* -EULA", "-* MIT"}, accepted: "MIT", rejected: "EULA GPL-2"},
+		{name: "* after -LICENSE", accept: []string{"-EULA *"}, accepted: "EULA"},
+		{name: "package.license", accept: []string{"@FREE"}, pkgLic: []string{"app-misc/foo EULA"}, accepted: "EULA MIT"},
+		{name: "package.license other package", accept: []string{"@FREE"}, pkgLic: []string{"app-misc/bar EULA"},
+			rejected: "EULA"},
+		{name: "package.license removes", accept: []string{"@FREE"}, pkgLic: []string{"app-misc/foo -@GPL-COMPATIBLE"},
+			accepted: "Apache-2.0", rejected: "GPL-2"},
+		{name: "package.license -*", accept: []string{"*"}, pkgLic: []string{"=app-misc/foo-1.0 -* MIT"},
+			accepted: "MIT", rejected: "GPL-2"},
+	}
+	for _, tt := range tests {
+		var accept []string
+		for _, layer := range tt.accept {
+			accept = append(accept, strings.Fields(layer)...)
+		}
+		r := NewLicenseRule(accept)
+		if err := r.LoadGroups(groups); err != nil {
+			t.Fatal(err)
+		}
+		if tt.pkgLic != nil {
+			if err := r.LoadPackageLicenses(writeConfig(t, "package.license", tt.pkgLic...)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		a := r.accepted(pkg.NewPackage("app-misc/foo", "1.0", "0"))
+		for _, l := range strings.Fields(tt.accepted) {
+			if !a.ok(l) {
+				t.Errorf("%s: %s rejected, want accepted", tt.name, l)
+			}
+		}
+		for _, l := range strings.Fields(tt.rejected) {
+			if a.ok(l) {
+				t.Errorf("%s: %s accepted, want rejected", tt.name, l)
+			}
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package visibility
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/kolkov/gportage/internal/config"
+	"github.com/kolkov/gportage/internal/pkg"
+)
+
+// maskEntry - запись package.mask вместе с блоком комментариев, объясняющим причину
+type maskEntry struct {
+	atom    *pkg.Atom
+	comment []string
+	source  string
+}
+
+// MaskRule скрывает версии, перечисленные в package.mask и не снятые package.unmask
+type MaskRule struct {
+	masks   []maskEntry
+	unmasks []*pkg.Atom
+}
+
+func NewMaskRule() *MaskRule {
+	return &MaskRule{}
+}
+
+// LoadMasks читает package.mask (файл или каталог)
+func (r *MaskRule) LoadMasks(path string) error {
+	entries, err := config.ReadEntries(path)
+	if err != nil {
+		return err
+	}
+	r.AddMasks(entries)
+	return nil
+}
+
+// AddMasks добавляет записи package.mask. Запись "-atom" отменяет маску
+// с тем же атомом, добавленную ранее (используется в каскадных профилях).
+func (r *MaskRule) AddMasks(entries []config.Entry) {
+	for _, e := range entries {
+		text := e.Fields[0]
+		if strings.HasPrefix(text, "-") {
+			atom, err := pkg.ParseAtom(text[1:])
+			if err != nil {
+				log.Printf("Warning: %s: %v", e.Source, err)
+				continue
+			}
+			r.removeMask(atom)
+			continue
+		}
+
+		atom, err := pkg.ParseAtom(text)
+		if err != nil {
+			log.Printf("Warning: %s: %v", e.Source, err)
+			continue
+		}
+		r.masks = append(r.masks, maskEntry{atom: atom, comment: e.Comment, source: e.Source})
+	}
+}
+
+func (r *MaskRule) removeMask(atom *pkg.Atom) {
+	kept := r.masks[:0]
+	for _, m := range r.masks {
+		if !m.atom.Equal(atom) {
+			kept = append(kept, m)
+		}
+	}
+	r.masks = kept
+}
+
+// LoadUnmasks читает package.unmask (файл или каталог)
+func (r *MaskRule) LoadUnmasks(path string) error {
+	entries, err := config.ReadEntries(path)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		atom, err := pkg.ParseAtom(e.Fields[0])
+		if err != nil {
+			log.Printf("Warning: %s: %v", e.Source, err)
+			continue
+		}
+		r.unmasks = append(r.unmasks, atom)
+	}
+	return nil
+}
+
+// Check возвращает причину маскировки с комментарием из package.mask
+func (r *MaskRule) Check(p *pkg.Package) *Reason {
+	for _, unmask := range r.unmasks {
+		if unmask.Match(p) {
+			return nil
+		}
+	}
+
+	for _, m := range r.masks {
+		if !m.atom.Match(p) {
+			continue
+		}
+
+		message := "masked by: package.mask"
+		if len(m.comment) > 0 {
+			message += " (" + strings.Join(m.comment, " ") + ")"
+		}
+		return &Reason{
+			Kind:    ReasonMask,
+			Message: message,
+			Source:  m.source,
+			Change: &Change{
+				File:    "package.unmask",
+				Entry:   fmt.Sprintf("=%s-%s", p.Name, p.Version),
+				Comment: message,
+			},
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package visibility
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kolkov/gportage/internal/config"
+	"github.com/kolkov/gportage/internal/pkg"
+)
+
+func TestMaskRuleCheck(t *testing.T) {
+	base := []string{
+		"# Security issue, see bug #1",
+		"# Removal in 30 days",
+		"=app-misc/foo-1.0",
+		"",
+		"app-misc/bar",
+		"",
+		"dev-libs/baz[ssl,static]",
+	}
+
+	tests := []struct {
+		name    string
+		profile []string // package.mask дочернего профиля
+		unmask  []string
+		pkg     string
+		version string
+		message string // Пустая строка - пакет видим
+	}{
+		{name: "masked with comment", pkg: "app-misc/foo", version: "1.0",
+			message: "masked by: package.mask (Security issue, see bug #1 Removal in 30 days)"},
+		{name: "masked without comment", pkg: "app-misc/bar", version: "2",
+			message: "masked by: package.mask"},
+		{name: "not masked version", pkg: "app-misc/foo", version: "1.1"},
+		{name: "profile removes mask", profile: []string{"-=app-misc/foo-1.0"}, pkg: "app-misc/foo", version: "1.0"},
+		{name: "profile removes mask with other spelling", profile: []string{"-=app-misc/foo-1.0-r0"},
+			pkg: "app-misc/foo", version: "1.0"},
+		{name: "profile removes mask with reordered USE", profile: []string{"-dev-libs/baz[static,ssl]"},
+			pkg: "dev-libs/baz", version: "1"},
+		{name: "profile removal of other atom", profile: []string{"-<app-misc/foo-2"}, pkg: "app-misc/foo", version: "1.0",
+			message: "masked by: package.mask (Security issue, see bug #1 Removal in 30 days)"},
+		{name: "profile adds mask", profile: []string{"# Broken", ">=app-misc/foo-1.1"}, pkg: "app-misc/foo", version: "1.1",
+			message: "masked by: package.mask (Broken)"},
+		{name: "unmask overrides mask", unmask: []string{"app-misc/bar"}, pkg: "app-misc/bar", version: "2"},
+		{name: "unmask of other version", unmask: []string{">=app-misc/bar-3"}, pkg: "app-misc/bar", version: "2",
+			message: "masked by: package.mask"},
+	}
+	for _, tt := range tests {
+		r := NewMaskRule()
+		if err := r.LoadMasks(writeConfig(t, "package.mask", base...)); err != nil {
+			t.Fatal(err)
+		}
+		if tt.profile != nil {
+			entries, err := config.ReadEntries(writeConfig(t, "package.mask", tt.profile...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.AddMasks(entries)
+		}
+		if tt.unmask != nil {
+			if err := r.LoadUnmasks(writeConfig(t, "package.unmask", tt.unmask...)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		reason := r.Check(pkg.NewPackage(tt.pkg, tt.version, "0"))
+		switch {
+		case tt.message == "" && reason != nil:
+			t.Errorf("%s: Check() = %q, want visible", tt.name, reason.Message)
+		case tt.message != "" && reason == nil:
+			t.Errorf("%s: Check() = visible, want %q", tt.name, tt.message)
+		case reason != nil:
+			if reason.Kind != ReasonMask || reason.Message != tt.message {
+				t.Errorf("%s: Check() = %q, want %q", tt.name, reason.Message, tt.message)
+			}
+			if !strings.Contains(reason.Source, "package.mask:") {
+				t.Errorf("%s: Source = %q, want package.mask line", tt.name, reason.Source)
+			}
+			entry := "=" + tt.pkg + "-" + tt.version
+			if reason.Change == nil || reason.Change.File != "package.unmask" ||
+				reason.Change.Entry != entry || reason.Change.Comment != tt.message {
+				t.Errorf("%s: Change = %+v, want package.unmask %s", tt.name, reason.Change, entry)
+			}
+		}
+	}
+}